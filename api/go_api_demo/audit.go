@@ -0,0 +1,354 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: audit.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Per-user audit history. A UserRepository decorator records an
+// immutable entry for every write, with the actor, request ID and a field
+// diff, and the history is served at /api/v1/users/{id}/history.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditAction is the kind of write an audit entry records.
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+)
+
+// AuditEntry is an immutable record of one change to a user.
+type AuditEntry struct {
+	ID     string      `json:"id"`
+	UserID string      `json:"userId"`
+	OrgID  string      `json:"orgId"`
+	Action AuditAction `json:"action"`
+	// Actor is the principal that made the change, or "system" outside a request.
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"requestId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Changes lists every field whose value differs between before and after.
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is the before and after value of one field, by JSON name.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditListOptions controls pagination for AuditLog.List.
+type AuditListOptions struct {
+	UserID string
+	// OrgID, when set, hides entries recorded in other organizations.
+	OrgID string
+	// Limit is the maximum number of entries to return. Zero means defaultListLimit.
+	Limit int
+	// Cursor is the opaque NextCursor from a previous page.
+	Cursor string
+}
+
+// AuditPage is a page of entries, newest first.
+type AuditPage struct {
+	Entries    []AuditEntry
+	NextCursor string
+}
+
+// AuditLog is an append-only store of audit entries. There is deliberately no
+// way to change or remove an entry once appended; history outlives purges.
+type AuditLog interface {
+	Append(ctx context.Context, entry AuditEntry) error
+	List(ctx context.Context, opts AuditListOptions) (AuditPage, error)
+}
+
+// InMemoryAuditLog is a thread-safe, in-memory AuditLog.
+type InMemoryAuditLog struct {
+	mu      sync.RWMutex
+	entries map[string][]AuditEntry // user ID -> entries, oldest first
+}
+
+// NewInMemoryAuditLog creates an empty audit log.
+func NewInMemoryAuditLog() *InMemoryAuditLog {
+	return &InMemoryAuditLog{entries: make(map[string][]AuditEntry)}
+}
+
+// Append adds entry to its user's history.
+func (l *InMemoryAuditLog) Append(ctx context.Context, entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.entries[entry.UserID] = append(l.entries[entry.UserID], entry)
+	return nil
+}
+
+// List returns a page of a user's history, newest first. The cursor is the
+// position in the user's append-only history, so pages are stable while new
+// entries are added.
+func (l *InMemoryAuditLog) List(ctx context.Context, opts AuditListOptions) (AuditPage, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return AuditPage{}, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	history := l.entries[opts.UserID]
+	end := len(history) // exclusive; entries are read backwards from here
+	if opts.Cursor != "" {
+		pos, err := decodeAuditCursor(opts.Cursor)
+		if err != nil || pos > len(history) {
+			return AuditPage{}, ErrInvalidCursor
+		}
+		end = pos
+	}
+
+	page := AuditPage{Entries: make([]AuditEntry, 0, min(limit, end))}
+	i := end - 1
+	for ; i >= 0 && len(page.Entries) < limit; i-- {
+		if opts.OrgID != "" && history[i].OrgID != opts.OrgID {
+			continue
+		}
+		page.Entries = append(page.Entries, history[i])
+	}
+	if i >= 0 {
+		page.NextCursor = encodeAuditCursor(i + 1)
+	}
+	return page, nil
+}
+
+func encodeAuditCursor(pos int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(pos)))
+}
+
+func decodeAuditCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	pos, err := strconv.Atoi(string(data))
+	if err != nil || pos < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return pos, nil
+}
+
+// auditedUserRepository is a UserRepository decorator that appends an audit
+// entry for every successful write. Writes are pinned to the version read for
+// the diff, so the recorded before state is exactly what was replaced.
+type auditedUserRepository struct {
+	UserRepository
+	log    AuditLog
+	ids    IDGenerator
+	logger *slog.Logger
+}
+
+// NewAuditedUserRepository wraps repo so its writes are recorded in log.
+func NewAuditedUserRepository(repo UserRepository, log AuditLog, ids IDGenerator, logger *slog.Logger) UserRepository {
+	return &auditedUserRepository{UserRepository: repo, log: log, ids: ids, logger: logger}
+}
+
+// Unwrap returns the wrapped repository.
+func (r *auditedUserRepository) Unwrap() UserRepository {
+	return r.UserRepository
+}
+
+// Create stores user and records its initial state.
+func (r *auditedUserRepository) Create(ctx context.Context, user User) (User, error) {
+	created, err := r.UserRepository.Create(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	r.record(ctx, AuditCreate, User{}, created)
+	return created, nil
+}
+
+// Update modifies a user and records what changed.
+func (r *auditedUserRepository) Update(ctx context.Context, id string, user User) (User, error) {
+	before, err := r.UserRepository.GetByID(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if user.Version == 0 {
+		user.Version = before.Version
+	}
+	updated, err := r.UserRepository.Update(ctx, id, user)
+	if err != nil {
+		return User{}, err
+	}
+	r.record(ctx, AuditUpdate, before, updated)
+	return updated, nil
+}
+
+// Delete soft-deletes a user and records the deletion.
+func (r *auditedUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	before, err := r.UserRepository.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		version = before.Version
+	}
+	if err := r.UserRepository.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	after, err := r.UserRepository.GetDeleted(ctx, id)
+	if err != nil {
+		// The delete succeeded, so record it even if the result has already
+		// changed again (e.g. been purged).
+		after = before
+		now := time.Now()
+		after.DeletedAt = &now
+	}
+	r.record(ctx, AuditDelete, before, after)
+	return nil
+}
+
+// Restore undeletes a user and records the restoration.
+func (r *auditedUserRepository) Restore(ctx context.Context, id string, version int64) (User, error) {
+	before, err := r.UserRepository.GetDeleted(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if version == 0 {
+		version = before.Version
+	}
+	restored, err := r.UserRepository.Restore(ctx, id, version)
+	if err != nil {
+		return User{}, err
+	}
+	r.record(ctx, AuditRestore, before, restored)
+	return restored, nil
+}
+
+// record appends an entry for a write that has already succeeded. A failure
+// to append is logged rather than returned, as the write cannot be undone.
+func (r *auditedUserRepository) record(ctx context.Context, action AuditAction, before, after User) {
+	entry := AuditEntry{
+		ID:        r.ids.NewID(),
+		UserID:    after.ID,
+		OrgID:     after.OrgID,
+		Action:    action,
+		Actor:     "system",
+		Timestamp: time.Now(),
+		Changes:   diffUsers(before, after),
+	}
+	if p, ok := principalFromContext(ctx); ok {
+		entry.Actor = p.ID
+	}
+	if meta := requestMetaFromContext(ctx); meta != nil {
+		entry.RequestID = meta.requestID
+	}
+
+	if err := r.log.Append(context.WithoutCancel(ctx), entry); err != nil {
+		r.logger.ErrorContext(ctx, "failed to append audit entry", "user_id", entry.UserID, "action", action, "error", err)
+	}
+}
+
+// diffUsers compares two users field by field, by JSON name. Version changes
+// on every write and is left out.
+func diffUsers(before, after User) []FieldChange {
+	changes := []FieldChange{}
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := range bv.NumField() {
+		name, _, _ := strings.Cut(bv.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "version" {
+			continue
+		}
+		b, a := fieldValue(bv.Field(i)), fieldValue(av.Field(i))
+		if !valuesEqual(b, a) {
+			changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+// fieldValue returns v as a plain value, with zero values and nil pointers as
+// nil so they are reported as absent.
+func fieldValue(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// valuesEqual reports whether two field values are the same. Times are
+// compared as instants, ignoring monotonic clock readings and locations.
+func valuesEqual(a, b any) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// userHistoryHandler returns a page of a user's audit history, newest first.
+// History is kept for deleted and purged users too.
+// GET /api/v1/users/{id}/history?limit=20&cursor=...
+// curl http://localhost:8080/api/v1/users/{id}/history
+func (app *application) userHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permUsersRead)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	opts, err := readListOptions(r)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := app.audit.List(r.Context(), AuditListOptions{
+		UserID: id,
+		OrgID:  m.OrgID,
+		Limit:  opts.Limit,
+		Cursor: opts.Cursor,
+	})
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+	if len(page.Entries) == 0 && opts.Cursor == "" {
+		app.writeError(w, r, http.StatusNotFound, "user not found")
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status:     "success",
+		Data:       page.Entries,
+		NextCursor: page.NextCursor,
+	})
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: auth.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Authentication middleware. Requests are authenticated either
+// with a static API key or with an HS256-signed JWT bearer token, and the
+// resulting principal is stored in the request context.
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyHeader carries a static API key.
+	apiKeyHeader = "X-API-Key"
+	// authRealm is advertised in WWW-Authenticate challenges.
+	authRealm = "go_api_demo"
+	// tokenLeeway tolerates small clock differences when checking exp and nbf.
+	tokenLeeway = 30 * time.Second
+)
+
+// Authentication methods recorded on a Principal.
+const (
+	authMethodAPIKey = "api_key"
+	authMethodBearer = "bearer"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// ID is the API key name or the token subject.
+	ID string
+	// Method is how the principal authenticated: "api_key" or "bearer".
+	Method string
+}
+
+type principalContextKey struct{}
+
+// contextWithPrincipal returns a copy of ctx carrying p.
+func contextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// principalFromContext returns the authenticated principal, if any.
+func principalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(Principal)
+	return p, ok
+}
+
+// errUnauthenticated is returned when a request carries no credentials at all.
+var errUnauthenticated = errors.New("authentication required")
+
+// apiKey is a configured key, stored as a hash so every comparison takes the
+// same time regardless of the candidate's length.
+type apiKey struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// authenticator verifies request credentials against the configured API keys
+// and token signing secret.
+type authenticator struct {
+	keys      []apiKey
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+// newAuthenticator builds an authenticator from keys (name -> key) and an
+// HS256 secret. At least one credential source must be configured.
+func newAuthenticator(keys map[string]string, jwtSecret string) (*authenticator, error) {
+	if len(keys) == 0 && jwtSecret == "" {
+		return nil, errors.New("no credentials configured: set API_KEYS and/or API_JWT_SECRET")
+	}
+
+	a := &authenticator{jwtSecret: []byte(jwtSecret), now: time.Now}
+	for name, key := range keys {
+		if name == "" || key == "" {
+			return nil, errors.New("API keys must have a non-empty name and value")
+		}
+		a.keys = append(a.keys, apiKey{name: name, hash: sha256.Sum256([]byte(key))})
+	}
+	return a, nil
+}
+
+// authenticate extracts and verifies the credentials on r.
+func (a *authenticator) authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return a.verifyAPIKey(key)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, errUnauthenticated
+	}
+	return a.verifyToken(strings.TrimSpace(token))
+}
+
+// verifyAPIKey compares key against every configured key in constant time.
+// All keys are checked, even after a match, so timing reveals nothing.
+func (a *authenticator) verifyAPIKey(key string) (Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+	var match string
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+			match = k.name
+		}
+	}
+	if match == "" {
+		return Principal{}, errors.New("invalid API key")
+	}
+	return Principal{ID: match, Method: authMethodAPIKey}, nil
+}
+
+// tokenClaims are the registered JWT claims the server understands.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+// verifyToken validates an HS256 JWT and returns its subject. Tokens must
+// carry both sub and exp.
+func (a *authenticator) verifyToken(token string) (Principal, error) {
+	if len(a.jwtSecret) == 0 {
+		return Principal{}, errors.New("bearer tokens are not accepted")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenSegment(parts[0], &header); err != nil {
+		return Principal{}, err
+	}
+	// Pinning the algorithm prevents "alg": "none" and key-confusion attacks.
+	if header.Alg != "HS256" {
+		return Principal{}, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return Principal{}, errors.New("invalid token signature")
+	}
+
+	var claims tokenClaims
+	if err := decodeTokenSegment(parts[1], &claims); err != nil {
+		return Principal{}, err
+	}
+	now := a.now()
+	switch {
+	case claims.Subject == "":
+		return Principal{}, errors.New("token has no subject")
+	case claims.ExpiresAt == 0:
+		return Principal{}, errors.New("token has no expiry")
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(tokenLeeway)):
+		return Principal{}, errors.New("token has expired")
+	case claims.NotBefore != 0 && now.Add(tokenLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return Principal{}, errors.New("token is not valid yet")
+	}
+
+	return Principal{ID: claims.Subject, Method: authMethodBearer}, nil
+}
+
+// decodeTokenSegment decodes a base64url JSON segment of a JWT into dst.
+func decodeTokenSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// authMiddleware authenticates every request for which isPublic returns
+// false. On success the principal is added to the request context; otherwise
+// a 401 with a WWW-Authenticate challenge is sent.
+func (app *application) authMiddleware(next http.Handler, isPublic func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublic(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := app.auth.authenticate(r)
+		if err != nil {
+			challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+			if !errors.Is(err, errUnauthenticated) && r.Header.Get(apiKeyHeader) == "" {
+				challenge += fmt.Sprintf(", error=\"invalid_token\", error_description=%q", err.Error())
+			}
+			w.Header().Add("WWW-Authenticate", challenge)
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf("APIKey realm=%q, header=%q", authRealm, apiKeyHeader))
+			app.writeError(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if meta := requestMetaFromContext(r.Context()); meta != nil {
+			meta.principal = principal.ID
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), principal)))
+	})
+}
@@ -0,0 +1,455 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: bulk.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Bulk import and export of users as NDJSON or CSV. Both
+// directions stream, so neither the upload nor the download is ever held in
+// memory as a whole.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	csvContentType    = "text/csv"
+
+	// maxImportBytes replaces Config.MaxBodyBytes for imports.
+	maxImportBytes = 64 << 20
+	// maxImportRows bounds the rows in one import, and so the size of the report.
+	maxImportRows = 100_000
+	// maxImportLineBytes bounds a single NDJSON line.
+	maxImportLineBytes = 64 << 10
+	// bulkTimeout replaces the server's read and write timeouts for bulk
+	// transfers, which can legitimately take longer.
+	bulkTimeout = 5 * time.Minute
+)
+
+// importRowStatus is the outcome of one imported row.
+type importRowStatus string
+
+const (
+	// rowCreated means the user was created.
+	rowCreated importRowStatus = "created"
+	// rowValid means the row would be created; only reported by dry runs.
+	rowValid importRowStatus = "valid"
+	// rowInvalid means the row could not be parsed or failed validation.
+	rowInvalid importRowStatus = "invalid"
+	// rowFailed means the row was valid but could not be stored.
+	rowFailed importRowStatus = "failed"
+)
+
+// importRowResult reports what happened to one row. Line is the row's line
+// number in the upload.
+type importRowResult struct {
+	Line   int             `json:"line"`
+	Status importRowStatus `json:"status"`
+	ID     string          `json:"id,omitempty"`
+	Errors []fieldError    `json:"errors,omitempty"`
+}
+
+// importResult summarizes an import.
+type importResult struct {
+	DryRun bool `json:"dryRun"`
+	Total  int  `json:"total"`
+	// Succeeded counts created rows, or valid rows in a dry run.
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Aborted explains why the upload was not read to the end, if it wasn't.
+	// Rows before that point have still been processed.
+	Aborted string            `json:"aborted,omitempty"`
+	Rows    []importRowResult `json:"rows"`
+}
+
+// importRecord is one decoded row. err is set if the row could not be decoded.
+type importRecord struct {
+	line  int
+	input userInput
+	err   error
+}
+
+// recordReader yields import records. It returns io.EOF at the end of the
+// upload, and any other error if the upload cannot be read any further.
+type recordReader interface {
+	next() (importRecord, error)
+}
+
+// ndjsonReader reads one JSON object per line. Blank lines are skipped, and a
+// line that is too long is reported as invalid without stopping the import.
+type ndjsonReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	return &ndjsonReader{r: bufio.NewReaderSize(r, maxImportLineBytes)}
+}
+
+func (nr *ndjsonReader) next() (importRecord, error) {
+	for {
+		data, err := nr.r.ReadSlice('\n')
+		if len(data) == 0 && err != nil {
+			return importRecord{}, err
+		}
+		nr.line++
+		rec := importRecord{line: nr.line}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// Skip the rest of the line.
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = nr.r.ReadSlice('\n')
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				return importRecord{}, err
+			}
+			rec.err = fmt.Errorf("line is longer than %dKB", maxImportLineBytes>>10)
+			return rec, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return importRecord{}, err
+		}
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err != nil {
+				return importRecord{}, err
+			}
+			continue
+		}
+		// Only name and email are read, so an export can be imported as is.
+		if err := json.Unmarshal(data, &rec.input); err != nil {
+			rec.err = errors.New("line is not a valid JSON object")
+		}
+		return rec, nil
+	}
+}
+
+// csvReader reads rows of a CSV file whose header row names the columns.
+// name and email are required; other columns are ignored.
+type csvReader struct {
+	r                 *csv.Reader
+	nameCol, emailCol int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // checked per row, so one bad row does not stop the import
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the CSV upload is empty; it must start with a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	header = slices.Clone(header)
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	reader := &csvReader{r: cr, nameCol: slices.Index(header, "name"), emailCol: slices.Index(header, "email")}
+	if reader.nameCol < 0 || reader.emailCol < 0 {
+		return nil, errors.New("the CSV header row must contain name and email columns")
+	}
+	return reader, nil
+}
+
+func (cr *csvReader) next() (importRecord, error) {
+	row, err := cr.r.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		return importRecord{line: parseErr.StartLine, err: errors.New(parseErr.Err.Error())}, nil
+	case err != nil:
+		return importRecord{}, err
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	rec := importRecord{line: line}
+	if len(row) <= max(cr.nameCol, cr.emailCol) {
+		rec.err = fmt.Errorf("row has %d columns but the header has more", len(row))
+		return rec, nil
+	}
+	rec.input = userInput{
+		Name:  strings.TrimSpace(row[cr.nameCol]),
+		Email: strings.TrimSpace(row[cr.emailCol]),
+	}
+	return rec, nil
+}
+
+// importUsersHandler creates users from an NDJSON or CSV upload, chosen by
+// Content-Type, and reports the outcome of every row. Rows are independent:
+// invalid rows are reported and skipped, and valid ones are created even if
+// others fail. With dry_run=true nothing is created, but every row is
+// checked, including for emails that are already taken.
+// POST /api/v1/users:import?dry_run=true
+//
+//	curl -X POST -H "Content-Type: text/csv" \
+//	 --data-binary @users.csv \
+//	 http://localhost:8080/api/v1/users:import
+func (app *application) importUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
+	}
+
+	result := importResult{Rows: make([]importRowResult, 0)}
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			app.writeError(w, r, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+		result.DryRun = dryRun
+	}
+
+	// Imports are exempt from the limit on other bodies, and from the
+	// server's timeouts, which a large upload could not meet.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(bulkTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.logger.WarnContext(r.Context(), "failed to extend read deadline for import", "error", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.logger.WarnContext(r.Context(), "failed to extend write deadline for import", "error", err)
+	}
+
+	var records recordReader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case ndjsonContentType, "application/ndjson", "application/jsonl":
+		records = newNDJSONReader(r.Body)
+	case csvContentType:
+		cr, err := newCSVReader(r.Body)
+		if err != nil {
+			app.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		records = cr
+	default:
+		w.Header().Set("Accept-Post", ndjsonContentType+", "+csvContentType)
+		app.writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+ndjsonContentType+" or "+csvContentType)
+		return
+	}
+
+	trans := requestTranslator(r)
+	seen := make(map[string]int) // email key -> line it first appeared on
+	for {
+		rec, err := records.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			result.Aborted = importAbortReason(err)
+			break
+		}
+		if result.Total == maxImportRows {
+			result.Aborted = fmt.Sprintf("the upload has more than %d rows", maxImportRows)
+			break
+		}
+
+		row := app.importRow(r.Context(), users, trans, result.DryRun, seen, rec)
+		result.Total++
+		if row.Status == rowCreated || row.Status == rowValid {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	message := fmt.Sprintf("Imported %d of %d users", result.Succeeded, result.Total)
+	if result.DryRun {
+		message = fmt.Sprintf("%d of %d users would be imported", result.Succeeded, result.Total)
+	}
+	if result.Aborted != "" {
+		message += "; the upload was not read to the end"
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: message,
+		Data:    result,
+	})
+}
+
+// importRow validates one record and, unless dryRun is set, creates the user.
+// seen tracks the emails of earlier rows, so duplicates within the upload are
+// caught even in a dry run.
+func (app *application) importRow(ctx context.Context, users UserRepository, trans ut.Translator, dryRun bool, seen map[string]int, rec importRecord) importRowResult {
+	row := importRowResult{Line: rec.line, Status: rowInvalid}
+	if rec.err != nil {
+		row.Errors = []fieldError{{Tag: "parse", Message: rec.err.Error()}}
+		return row
+	}
+
+	if err := validate.Struct(rec.input); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			row.Errors = []fieldError{{Tag: "parse", Message: err.Error()}}
+			return row
+		}
+		for _, fe := range validationErrors {
+			row.Errors = append(row.Errors, fieldError{Field: fe.Field(), Tag: fe.Tag(), Message: validationMessage(fe, trans)})
+		}
+		return row
+	}
+
+	user := User{Name: rec.input.Name, Email: rec.input.Email}
+	key := emailKey(user)
+	if first, ok := seen[key]; ok {
+		row.Errors = []fieldError{{Field: "email", Tag: "unique", Message: fmt.Sprintf("email already appears on line %d", first)}}
+		return row
+	}
+	seen[key] = rec.line
+
+	if dryRun {
+		page, err := users.List(ctx, ListOptions{Email: user.Email, Limit: 1, Sort: SortCreatedAtAsc})
+		switch {
+		case err != nil:
+			app.logger.ErrorContext(ctx, "failed to check email during import dry run", "error", err)
+			row.Status = rowFailed
+			row.Errors = []fieldError{{Tag: "internal", Message: "the row could not be checked"}}
+		case len(page.Users) > 0:
+			row.Status = rowFailed
+			row.Errors = []fieldError{{Field: "email", Tag: "unique", Message: "a user with this email address already exists"}}
+		default:
+			row.Status = rowValid
+		}
+		return row
+	}
+
+	user.ID = app.ids.NewID()
+	user.CreatedAt = time.Now()
+	created, err := users.Create(ctx, user)
+	switch {
+	case errors.Is(err, ErrDuplicateEmail):
+		row.Status = rowFailed
+		row.Errors = []fieldError{{Field: "email", Tag: "unique", Message: "a user with this email address already exists"}}
+	case err != nil:
+		app.logger.ErrorContext(ctx, "failed to create user during import", "line", rec.line, "error", err)
+		row.Status = rowFailed
+		row.Errors = []fieldError{{Tag: "internal", Message: "the user could not be created"}}
+	default:
+		row.Status = rowCreated
+		row.ID = created.ID
+	}
+	return row
+}
+
+// importAbortReason describes an error that stopped an upload being read.
+func importAbortReason(err error) string {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("the upload is larger than %dMB", maxImportBytes>>20)
+	}
+	return "the upload could not be read: " + err.Error()
+}
+
+// exportUsersHandler streams every active user in the caller's organization
+// as NDJSON (the default) or CSV, page by page, so memory use does not grow
+// with the number of users. The format is taken from the format query
+// parameter, or else from Accept. Users changed during the export may or may
+// not be included.
+// GET /api/v1/users:export?format=csv
+// curl -o users.ndjson http://localhost:8080/api/v1/users:export
+func (app *application) exportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, ok := app.scopedUsers(w, r, permUsersRead)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+		if strings.Contains(r.Header.Get("Accept"), csvContentType) {
+			format = "csv"
+		}
+	}
+
+	var write func(User) error
+	var flush func() error
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="users.ndjson"`)
+		enc := json.NewEncoder(w)
+		write = func(u User) error { return enc.Encode(u) }
+		flush = func() error { return nil }
+	case "csv":
+		w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"id", "orgId", "createdAt", "name", "email", "version"}); err != nil {
+			return
+		}
+		write = func(u User) error {
+			return cw.Write([]string{u.ID, u.OrgID, u.CreatedAt.Format(time.RFC3339Nano), u.Name, u.Email, strconv.FormatInt(u.Version, 10)})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		app.writeError(w, r, http.StatusBadRequest, "format must be ndjson or csv")
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(bulkTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.logger.WarnContext(r.Context(), "failed to extend write deadline for export", "error", err)
+	}
+
+	opts := ListOptions{Limit: maxListLimit, Sort: SortCreatedAtAsc}
+	for {
+		page, err := users.List(r.Context(), opts)
+		if err != nil && r.Context().Err() != nil {
+			return // the client has gone away
+		}
+		if err != nil {
+			// The status line has been sent, so the only way to tell the
+			// client the export is incomplete is to break the connection.
+			app.logger.ErrorContext(r.Context(), "export failed", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		for _, u := range page.Users {
+			if err := write(u); err != nil {
+				return // the client has gone away
+			}
+		}
+		if err := flush(); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return
+		}
+
+		if page.NextCursor == "" {
+			return
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: cache.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: A read-through cache for UserRepository.GetByID. A bounded LRU
+// holds users, and recent misses, for a limited time; writes invalidate
+// entries immediately, and concurrent misses for the same ID share one load.
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a cached GetByID result: a user, or the knowledge that there
+// is none.
+type cacheEntry struct {
+	id       string
+	user     User
+	notFound bool
+	expires  time.Time
+}
+
+// cacheLoad is a GetByID call on the wrapped repository that any number of
+// callers wait for. user and err are set before done is closed.
+type cacheLoad struct {
+	done chan struct{}
+	user User
+	err  error
+}
+
+// cachingUserRepository is a UserRepository decorator that serves GetByID
+// from memory. Other reads are passed through, as their results depend on
+// more than one user. Soft-deleted users are not found by GetByID, so they
+// are cached as misses, and Purge, which only removes soft-deleted users,
+// needs no invalidation.
+type cachingUserRepository struct {
+	UserRepository
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	lookups     *counterVec
+	now         func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List               // of *cacheEntry, most recently used first
+	entries map[string]*list.Element // by user ID
+	loads   map[string]*cacheLoad    // in flight, by user ID
+}
+
+// NewCachingUserRepository wraps repo so up to capacity GetByID results are
+// cached, users for ttl and misses for negativeTTL (0 disables caching
+// misses). Lookups are counted as hits or misses in m.
+func NewCachingUserRepository(repo UserRepository, capacity int, ttl, negativeTTL time.Duration, m *appMetrics) UserRepository {
+	r := &cachingUserRepository{
+		UserRepository: repo,
+		capacity:       capacity,
+		ttl:            ttl,
+		negativeTTL:    negativeTTL,
+		now:            time.Now,
+		lru:            list.New(),
+		entries:        make(map[string]*list.Element),
+		loads:          make(map[string]*cacheLoad),
+	}
+	r.lookups = register(m.registry, newCounterVec("user_cache_lookups_total",
+		"User cache lookups by result: hit (served from the cache, including cached misses) or miss.",
+		"result"))
+	register(m.registry, newGaugeFunc("user_cache_entries", "Current number of entries in the user cache.",
+		func() (float64, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return float64(r.lru.Len()), nil
+		}))
+	return r
+}
+
+// Unwrap returns the wrapped repository.
+func (r *cachingUserRepository) Unwrap() UserRepository {
+	return r.UserRepository
+}
+
+// GetByID returns the cached result for id if it is still fresh, and loads it
+// from the wrapped repository otherwise. The load is shared with every other
+// caller missing on id at the same time, and it is not cancelled when one of
+// them gives up, so the others still get the result.
+func (r *cachingUserRepository) GetByID(ctx context.Context, id string) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	r.mu.Lock()
+	if elem, ok := r.entries[id]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if r.now().Before(entry.expires) {
+			r.lru.MoveToFront(elem)
+			r.mu.Unlock()
+			r.lookups.inc("hit")
+			if entry.notFound {
+				return User{}, ErrNotFound
+			}
+			return entry.user, nil
+		}
+		r.removeLocked(elem)
+	}
+
+	load, ok := r.loads[id]
+	if !ok {
+		load = &cacheLoad{done: make(chan struct{})}
+		r.loads[id] = load
+		go r.load(context.WithoutCancel(ctx), id, load)
+	}
+	r.mu.Unlock()
+	r.lookups.inc("miss")
+
+	select {
+	case <-load.done:
+		return load.user, load.err
+	case <-ctx.Done():
+		return User{}, ctx.Err()
+	}
+}
+
+// load fetches id from the wrapped repository and caches the result, unless
+// the user was written in the meantime, in which case the result may predate
+// the write and is only handed to the callers already waiting for it.
+func (r *cachingUserRepository) load(ctx context.Context, id string, load *cacheLoad) {
+	load.user, load.err = r.UserRepository.GetByID(ctx, id)
+
+	r.mu.Lock()
+	if r.loads[id] == load {
+		delete(r.loads, id)
+		switch {
+		case load.err == nil:
+			r.storeLocked(&cacheEntry{id: id, user: load.user, expires: r.now().Add(r.ttl)})
+		case errors.Is(load.err, ErrNotFound) && r.negativeTTL > 0:
+			r.storeLocked(&cacheEntry{id: id, notFound: true, expires: r.now().Add(r.negativeTTL)})
+		}
+	}
+	r.mu.Unlock()
+	close(load.done)
+}
+
+// storeLocked caches entry, evicting the least recently used entry if the
+// cache is full. The caller must hold the lock.
+func (r *cachingUserRepository) storeLocked(entry *cacheEntry) {
+	if elem, ok := r.entries[entry.id]; ok {
+		elem.Value = entry
+		r.lru.MoveToFront(elem)
+		return
+	}
+	r.entries[entry.id] = r.lru.PushFront(entry)
+	if r.lru.Len() > r.capacity {
+		r.removeLocked(r.lru.Back())
+	}
+}
+
+// removeLocked drops an entry. The caller must hold the lock.
+func (r *cachingUserRepository) removeLocked(elem *list.Element) {
+	r.lru.Remove(elem)
+	delete(r.entries, elem.Value.(*cacheEntry).id)
+}
+
+// invalidate forgets id, and stops a load in flight from caching what may be
+// the state before the write. Writes call it once they have returned, whether
+// or not they succeeded: a failed write, such as a version conflict, may mean
+// the cached copy is out of date.
+func (r *cachingUserRepository) invalidate(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.entries[id]; ok {
+		r.removeLocked(elem)
+	}
+	delete(r.loads, id)
+}
+
+// Create stores user and forgets any cached miss for its ID.
+func (r *cachingUserRepository) Create(ctx context.Context, user User) (User, error) {
+	defer r.invalidate(user.ID)
+	return r.UserRepository.Create(ctx, user)
+}
+
+// Update modifies a user and invalidates it.
+func (r *cachingUserRepository) Update(ctx context.Context, id string, user User) (User, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Update(ctx, id, user)
+}
+
+// Delete soft-deletes a user and invalidates it.
+func (r *cachingUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	defer r.invalidate(id)
+	return r.UserRepository.Delete(ctx, id, version)
+}
+
+// Restore undeletes a user and invalidates it.
+func (r *cachingUserRepository) Restore(ctx context.Context, id string, version int64) (User, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Restore(ctx, id, version)
+}
@@ -0,0 +1,519 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: config.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Layered configuration. Every setting is declared once and can
+// come from its default, a JSON or TOML config file, an API_* environment
+// variable or a command-line flag, each layer overriding the one before.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets when the configuration is printed.
+const redacted = "[REDACTED]"
+
+// errRedacted is returned when a printed configuration is loaded back without
+// its secrets being filled in.
+var errRedacted = errors.New("value is redacted; set the real secret")
+
+// setting is one configuration value. Its key names it in config files; the
+// flag name is the key with dashes instead of underscores.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	// set parses s into c; get returns the value of c for printing.
+	set func(c *Config, s string) error
+	get func(c *Config) any
+	// redact, if set, replaces get when printing, so secrets are hidden.
+	redact func(c *Config) any
+	// boolFlag lets the flag be given without a value, meaning true.
+	boolFlag bool
+}
+
+// newSetting declares a setting stored in the field returned by ptr.
+func newSetting[T any](key, env, usage string, ptr func(*Config) *T, parse func(string) (T, error), format func(T) any) setting {
+	_, isBool := any(*new(T)).(bool)
+	return setting{
+		key:      key,
+		env:      env,
+		usage:    usage,
+		boolFlag: isBool,
+		set: func(c *Config, s string) error {
+			v, err := parse(s)
+			if err != nil {
+				return err
+			}
+			*ptr(c) = v
+			return nil
+		},
+		get: func(c *Config) any { return format(*ptr(c)) },
+	}
+}
+
+func parseString(s string) (string, error) { return s, nil }
+func formatString(s string) any            { return s }
+func formatInt(n int) any                  { return n }
+func formatBool(b bool) any                { return b }
+
+// parseList parses a comma-separated list, dropping empty entries.
+func parseList(s string) ([]string, error) {
+	var list []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func formatList(list []string) any       { return strings.Join(list, ",") }
+func formatDuration(d time.Duration) any { return d.String() }
+
+// parseByteSize parses a size in bytes, with an optional KB, MB or GB suffix
+// (powers of 1024).
+func parseByteSize(s string) (int64, error) {
+	num, mult := strings.TrimSpace(s), int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if trimmed, ok := strings.CutSuffix(strings.ToUpper(num), suffix); ok {
+			num, mult = strings.TrimSpace(trimmed), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n > (1<<62)/mult {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// formatByteSize formats n in the largest unit that divides it exactly.
+func formatByteSize(n int64) string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n >= unit.size && n%unit.size == 0 {
+			return strconv.FormatInt(n/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
+
+// formatAPIKeys formats keys in the name:key form parsed by parseAPIKeys.
+// With redact set, only the names are shown.
+func formatAPIKeys(keys map[string]string, redact bool) string {
+	pairs := make([]string, 0, len(keys))
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		key := keys[name]
+		if redact {
+			key = redacted
+		}
+		pairs = append(pairs, name+":"+key)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// settings lists every configuration value, in the order they are printed.
+var settings = []setting{
+	newSetting("port", "API_PORT", "port the HTTP server listens on",
+		func(c *Config) *string { return &c.Port }, parseString, formatString),
+	newSetting("read_timeout", "API_READ_TIMEOUT", "maximum time to read a request, including its body",
+		func(c *Config) *time.Duration { return &c.ReadTimeout }, time.ParseDuration, formatDuration),
+	newSetting("write_timeout", "API_WRITE_TIMEOUT", "maximum time to write a response",
+		func(c *Config) *time.Duration { return &c.WriteTimeout }, time.ParseDuration, formatDuration),
+	newSetting("idle_timeout", "API_IDLE_TIMEOUT", "how long an idle keep-alive connection is kept open",
+		func(c *Config) *time.Duration { return &c.IdleTimeout }, time.ParseDuration, formatDuration),
+	newSetting("tls_cert_file", "API_TLS_CERT_FILE", "PEM certificate file; enables HTTPS and is reloaded when it changes",
+		func(c *Config) *string { return &c.TLSCertFile }, parseString, formatString),
+	newSetting("tls_key_file", "API_TLS_KEY_FILE", "PEM private key file for tls_cert_file",
+		func(c *Config) *string { return &c.TLSKeyFile }, parseString, formatString),
+	newSetting("tls_self_signed", "API_TLS_SELF_SIGNED", "serve HTTPS with a certificate generated at startup, for development",
+		func(c *Config) *bool { return &c.TLSSelfSigned }, strconv.ParseBool, formatBool),
+	newSetting("tls_min_version", "API_TLS_MIN_VERSION", "minimum TLS version: 1.2 or 1.3",
+		func(c *Config) *string { return &c.TLSMinVersion }, parseString, formatString),
+	newSetting("tls_cipher_suites", "API_TLS_CIPHER_SUITES", "comma-separated TLS 1.2 cipher suites to allow; empty uses Go's defaults",
+		func(c *Config) *string { return &c.TLSCipherSuites }, parseString, formatString),
+	newSetting("http_redirect_port", "API_HTTP_REDIRECT_PORT", "port on which plain HTTP is redirected to HTTPS; empty disables",
+		func(c *Config) *string { return &c.HTTPRedirectPort }, parseString, formatString),
+	newSetting("cors_allowed_origins", "API_CORS_ALLOWED_ORIGINS", "comma-separated origins allowed to call the API from a browser, or *; empty disables CORS",
+		func(c *Config) *[]string { return &c.CORSAllowedOrigins }, parseList, formatList),
+	newSetting("cors_max_age", "API_CORS_MAX_AGE", "how long browsers may cache a preflight response",
+		func(c *Config) *time.Duration { return &c.CORSMaxAge }, time.ParseDuration, formatDuration),
+	newSetting("compression", "API_COMPRESSION", "compress responses with gzip or deflate when the client accepts it",
+		func(c *Config) *bool { return &c.Compression }, strconv.ParseBool, formatBool),
+	newSetting("max_body_bytes", "API_MAX_BODY_BYTES", "largest accepted request body, e.g. 1MB",
+		func(c *Config) *int64 { return &c.MaxBodyBytes }, parseByteSize, func(n int64) any { return formatByteSize(n) }),
+	newSetting("shutdown_delay", "API_SHUTDOWN_DELAY", "how long to keep serving, unready, after a stop signal",
+		func(c *Config) *time.Duration { return &c.ShutdownDelay }, time.ParseDuration, formatDuration),
+	newSetting("shutdown_timeout", "API_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests, and then for webhook deliveries, when stopping",
+		func(c *Config) *time.Duration { return &c.ShutdownTimeout }, time.ParseDuration, formatDuration),
+	newSetting("storage", "API_STORAGE", "user storage backend: memory or file",
+		func(c *Config) *string { return &c.Storage }, parseString, formatString),
+	newSetting("data_dir", "API_DATA_DIR", "directory holding the file backend's log and snapshot",
+		func(c *Config) *string { return &c.DataDir }, parseString, formatString),
+	newSetting("snapshot_interval", "API_SNAPSHOT_INTERVAL", "how often the file backend compacts its log",
+		func(c *Config) *time.Duration { return &c.SnapshotInterval }, time.ParseDuration, formatDuration),
+	{
+		key: "api_keys", env: "API_KEYS", usage: "comma-separated name:key API keys",
+		set: func(c *Config, s string) error {
+			if strings.Contains(s, redacted) {
+				return errRedacted
+			}
+			keys, err := parseAPIKeys(s)
+			if err != nil {
+				return err
+			}
+			c.APIKeys = keys
+			return nil
+		},
+		get:    func(c *Config) any { return formatAPIKeys(c.APIKeys, false) },
+		redact: func(c *Config) any { return formatAPIKeys(c.APIKeys, true) },
+	},
+	{
+		key: "jwt_secret", env: "API_JWT_SECRET", usage: "HS256 secret for bearer tokens",
+		set: func(c *Config, s string) error {
+			if strings.Contains(s, redacted) {
+				return errRedacted
+			}
+			c.JWTSecret = s
+			return nil
+		},
+		get: func(c *Config) any { return c.JWTSecret },
+		redact: func(c *Config) any {
+			if c.JWTSecret == "" {
+				return ""
+			}
+			return redacted
+		},
+	},
+	newSetting("cache_size", "API_CACHE_SIZE", "number of users kept in the read-through cache; 0 disables it",
+		func(c *Config) *int { return &c.CacheSize }, strconv.Atoi, formatInt),
+	newSetting("cache_ttl", "API_CACHE_TTL", "how long a cached user is served",
+		func(c *Config) *time.Duration { return &c.CacheTTL }, time.ParseDuration, formatDuration),
+	newSetting("cache_negative_ttl", "API_CACHE_NEGATIVE_TTL", "how long a lookup of a missing user is cached; 0 disables it",
+		func(c *Config) *time.Duration { return &c.CacheNegativeTTL }, time.ParseDuration, formatDuration),
+	newSetting("soft_delete_retention", "API_SOFT_DELETE_RETENTION", "how long deleted users are kept before a purge removes them",
+		func(c *Config) *time.Duration { return &c.SoftDeleteRetention }, time.ParseDuration, formatDuration),
+	newSetting("id_format", "API_ID_FORMAT", "format of new IDs: uuidv7 or ulid",
+		func(c *Config) *string { return &c.IDFormat }, parseString, formatString),
+	newSetting("idempotency_ttl", "API_IDEMPOTENCY_TTL", "how long an Idempotency-Key response is replayed",
+		func(c *Config) *time.Duration { return &c.IdempotencyTTL }, time.ParseDuration, formatDuration),
+	newSetting("webhook_workers", "API_WEBHOOK_WORKERS", "number of concurrent webhook deliveries",
+		func(c *Config) *int { return &c.WebhookWorkers }, strconv.Atoi, formatInt),
+	newSetting("webhook_max_attempts", "API_WEBHOOK_MAX_ATTEMPTS", "attempts per webhook delivery before it is dead-lettered",
+		func(c *Config) *int { return &c.WebhookMaxAttempts }, strconv.Atoi, formatInt),
+}
+
+// defaultConfig returns the configuration used when nothing is overridden.
+func defaultConfig() Config {
+	return Config{
+		Port:                "8080",
+		ReadTimeout:         5 * time.Second,
+		WriteTimeout:        10 * time.Second,
+		IdleTimeout:         120 * time.Second,
+		TLSMinVersion:       "1.2",
+		CORSMaxAge:          10 * time.Minute,
+		Compression:         true,
+		MaxBodyBytes:        1 << 20,
+		ShutdownDelay:       5 * time.Second,
+		ShutdownTimeout:     30 * time.Second,
+		Storage:             "memory",
+		DataDir:             "data",
+		SnapshotInterval:    5 * time.Minute,
+		CacheSize:           10_000,
+		CacheTTL:            time.Minute,
+		CacheNegativeTTL:    5 * time.Second,
+		APIKeys:             map[string]string{},
+		SoftDeleteRetention: 30 * 24 * time.Hour,
+		IDFormat:            "uuidv7",
+		IdempotencyTTL:      24 * time.Hour,
+		WebhookWorkers:      4,
+		WebhookMaxAttempts:  8,
+	}
+}
+
+// loadConfig builds the configuration from, in increasing precedence, the
+// defaults, the config file named by -config or API_CONFIG, API_* environment
+// variables and the command-line flags in args. printConfig reports whether
+// -print-config was given. If the result fails validation, it is returned
+// along with the error, so it can still be printed.
+func loadConfig(args []string, getenv func(string) string) (cfg Config, printConfig bool, err error) {
+	cfg = defaultConfig()
+
+	fs := flag.NewFlagSet("go_api_demo", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("API_CONFIG"), "path to a JSON or TOML config file (env API_CONFIG)")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if def := fmt.Sprint(s.get(&cfg)); def != "" {
+			usage = fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, def)
+		}
+		record := func(v string) error {
+			flagValues[s.key] = v
+			return nil
+		}
+		if s.boolFlag {
+			fs.BoolFunc(strings.ReplaceAll(s.key, "_", "-"), usage, record)
+		} else {
+			fs.Func(strings.ReplaceAll(s.key, "_", "-"), usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, false, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *configPath != "" {
+		values, err := readConfigFile(*configPath)
+		if err != nil {
+			return cfg, false, err
+		}
+		for _, s := range settings {
+			if v, ok := values[s.key]; ok {
+				if err := s.set(&cfg, v); err != nil {
+					return cfg, false, fmt.Errorf("%s: %s: %w", *configPath, s.key, err)
+				}
+			}
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, false, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flagValues[s.key]; ok {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, false, fmt.Errorf("-%s: %w", strings.ReplaceAll(s.key, "_", "-"), err)
+			}
+		}
+	}
+
+	return cfg, printConfig, cfg.validate()
+}
+
+// validate checks that the configuration can be used, reporting every
+// problem at once.
+func (c Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Port), "port must be a number between 1 and 65535")
+	for name, d := range map[string]time.Duration{
+		"read_timeout":      c.ReadTimeout,
+		"write_timeout":     c.WriteTimeout,
+		"idle_timeout":      c.IdleTimeout,
+		"shutdown_timeout":  c.ShutdownTimeout,
+		"snapshot_interval": c.SnapshotInterval,
+		"idempotency_ttl":   c.IdempotencyTTL,
+	} {
+		check(d > 0, "%s must be positive", name)
+	}
+	check(c.CORSMaxAge >= 0, "cors_max_age must not be negative")
+	for _, origin := range c.CORSAllowedOrigins {
+		if err := validateCORSOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors_allowed_origins: %w", err))
+		}
+	}
+	check(c.ShutdownDelay >= 0, "shutdown_delay must not be negative")
+	check(c.SoftDeleteRetention >= 0, "soft_delete_retention must not be negative")
+	check(c.MaxBodyBytes > 0, "max_body_bytes must be positive")
+	check(c.Storage == "memory" || c.Storage == "file", "storage must be memory or file")
+	check(c.Storage != "file" || c.DataDir != "", "data_dir must be set for the file backend")
+	_, err := newIDGenerator(c.IDFormat)
+	check(err == nil, "id_format must be uuidv7 or ulid")
+	check(c.CacheSize >= 0, "cache_size must not be negative")
+	check(c.CacheSize == 0 || c.CacheTTL > 0, "cache_ttl must be positive")
+	check(c.CacheNegativeTTL >= 0, "cache_negative_ttl must not be negative")
+	check(c.WebhookWorkers >= 1, "webhook_workers must be at least 1")
+	check(c.WebhookMaxAttempts >= 1, "webhook_max_attempts must be at least 1")
+	check(len(c.APIKeys) > 0 || c.JWTSecret != "", "no credentials configured: set api_keys and/or jwt_secret")
+	errs = append(errs, c.validateTLS()...)
+
+	// Map iteration order is random; keep the report stable.
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// validPort reports whether s is a TCP port number a server can listen on.
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// writeConfig writes c as a JSON config file, with secrets redacted.
+func writeConfig(w io.Writer, c Config) error {
+	values := make(map[string]any, len(settings))
+	for _, s := range settings {
+		if s.redact != nil {
+			values[s.key] = s.redact(&c)
+		} else {
+			values[s.key] = s.get(&c)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
+
+// readConfigFile reads a flat config file into raw setting values. The format
+// is chosen by extension: .json for a JSON object, .toml for TOML.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".toml":
+		values, err = parseTOMLConfig(data)
+	default:
+		return nil, fmt.Errorf("%s: config files must be .json or .toml, not %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for key := range values {
+		if !slices.ContainsFunc(settings, func(s setting) bool { return s.key == key }) {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+	return values, nil
+}
+
+// parseJSONConfig reads a JSON object whose values are strings, numbers or
+// booleans.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, msg := range raw {
+		var s string
+		switch msg = bytes.TrimSpace(msg); {
+		case len(msg) > 0 && msg[0] == '"':
+			if err := json.Unmarshal(msg, &s); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		case len(msg) > 0 && (msg[0] == '{' || msg[0] == '['):
+			return nil, fmt.Errorf("%s: must be a string, number or boolean", key)
+		default:
+			s = string(msg)
+		}
+		values[key] = s
+	}
+	return values, nil
+}
+
+// parseTOMLConfig reads the subset of TOML a flat config needs: key = value
+// lines, where values are strings, integers or booleans, and # comments.
+// Tables and arrays are rejected.
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			return nil, fmt.Errorf("line %d: tables are not supported", lineNo)
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", lineNo, key)
+		}
+		values[key] = value
+	}
+	return values, sc.Err()
+}
+
+// parseTOMLValue parses a TOML string, integer or boolean, followed by an
+// optional comment.
+func parseTOMLValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		// Basic strings use the same escapes as Go for everything a config needs.
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return "", errors.New("unterminated string")
+		}
+		value, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		return value, checkTOMLTrailer(s[end+1:])
+	case strings.HasPrefix(s, "'"):
+		value, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return "", errors.New("unterminated string")
+		}
+		return value, checkTOMLTrailer(rest)
+	}
+
+	value, _, _ := strings.Cut(s, "#")
+	value = strings.TrimSpace(value)
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	if _, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64); err == nil {
+		return strings.ReplaceAll(value, "_", ""), nil
+	}
+	return "", fmt.Errorf("unsupported value %q; quote strings such as durations", value)
+}
+
+// checkTOMLTrailer rejects anything but a comment after a value.
+func checkTOMLTrailer(s string) error {
+	if s = strings.TrimSpace(s); s != "" && s[0] != '#' {
+		return fmt.Errorf("unexpected %q after value", s)
+	}
+	return nil
+}
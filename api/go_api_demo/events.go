@@ -0,0 +1,120 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: events.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: User change events. A UserRepository decorator publishes an
+// event after every successful write, and sinks such as the webhook
+// dispatcher fan them out to interested parties.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// UserEventType names a kind of change to a user.
+type UserEventType string
+
+const (
+	EventUserCreated  UserEventType = "user.created"
+	EventUserUpdated  UserEventType = "user.updated"
+	EventUserDeleted  UserEventType = "user.deleted"
+	EventUserRestored UserEventType = "user.restored"
+)
+
+// UserEvent describes one change to a user. Data is the user as it was right
+// after the change; for user.deleted it carries deletedAt.
+type UserEvent struct {
+	ID         string        `json:"id"`
+	Type       UserEventType `json:"type"`
+	OrgID      string        `json:"orgId"`
+	OccurredAt time.Time     `json:"occurredAt"`
+	Data       User          `json:"data"`
+}
+
+// UserEventSink receives user events. Publish is called synchronously after
+// the write has succeeded, so implementations must not block.
+type UserEventSink interface {
+	Publish(event UserEvent)
+}
+
+// eventingUserRepository is a UserRepository decorator that publishes a
+// UserEvent to every sink after each successful write.
+type eventingUserRepository struct {
+	UserRepository
+	ids    IDGenerator
+	logger *slog.Logger
+	sinks  []UserEventSink
+}
+
+// NewEventingUserRepository wraps repo so its writes are published to sinks.
+func NewEventingUserRepository(repo UserRepository, ids IDGenerator, logger *slog.Logger, sinks ...UserEventSink) UserRepository {
+	return &eventingUserRepository{UserRepository: repo, ids: ids, logger: logger, sinks: sinks}
+}
+
+// Unwrap returns the wrapped repository.
+func (r *eventingUserRepository) Unwrap() UserRepository {
+	return r.UserRepository
+}
+
+// Create stores user and publishes user.created.
+func (r *eventingUserRepository) Create(ctx context.Context, user User) (User, error) {
+	created, err := r.UserRepository.Create(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	r.publish(EventUserCreated, created)
+	return created, nil
+}
+
+// Update modifies a user and publishes user.updated.
+func (r *eventingUserRepository) Update(ctx context.Context, id string, user User) (User, error) {
+	updated, err := r.UserRepository.Update(ctx, id, user)
+	if err != nil {
+		return User{}, err
+	}
+	r.publish(EventUserUpdated, updated)
+	return updated, nil
+}
+
+// Delete soft-deletes a user and publishes user.deleted.
+func (r *eventingUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	if err := r.UserRepository.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	deleted, err := r.UserRepository.GetDeleted(context.WithoutCancel(ctx), id)
+	if err != nil {
+		// Only possible if the user was purged in the meantime.
+		r.logger.WarnContext(ctx, "deleted user vanished before its event was published", "user_id", id, "error", err)
+		return nil
+	}
+	r.publish(EventUserDeleted, deleted)
+	return nil
+}
+
+// Restore undeletes a user and publishes user.restored.
+func (r *eventingUserRepository) Restore(ctx context.Context, id string, version int64) (User, error) {
+	restored, err := r.UserRepository.Restore(ctx, id, version)
+	if err != nil {
+		return User{}, err
+	}
+	r.publish(EventUserRestored, restored)
+	return restored, nil
+}
+
+func (r *eventingUserRepository) publish(typ UserEventType, user User) {
+	event := UserEvent{
+		ID:         r.ids.NewID(),
+		Type:       typ,
+		OrgID:      user.OrgID,
+		OccurredAt: time.Now(),
+		Data:       user,
+	}
+	for _, sink := range r.sinks {
+		sink.Publish(event)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: file_repository.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: A durable, file-backed UserRepository. Every write is appended
+// to a write-ahead log and fsynced before it is acknowledged; the log is
+// replayed at startup and periodically compacted into a snapshot.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	walFileName      = "users.wal"
+	snapshotFileName = "users.snapshot.json"
+)
+
+// snapshot is the on-disk representation of a compacted user store.
+type snapshot struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Users     []User    `json:"users"`
+}
+
+// FileUserRepository persists users to a directory on local disk.
+//
+// It reuses InMemoryUserRepository for reads and validation, and hooks into its
+// commit step so each mutation is written to the log before it becomes visible.
+// Because the in-memory write lock is held while logging, the log order always
+// matches the order in which mutations were applied.
+type FileUserRepository struct {
+	*InMemoryUserRepository
+
+	dir    string
+	logger *slog.Logger
+
+	// compactMu serializes compactions. The WAL handle itself is guarded by
+	// the embedded repository's lock: writers hold it exclusively, and
+	// compaction holds it for reading, which excludes writers.
+	compactMu sync.Mutex
+	wal       *os.File
+}
+
+// NewFileUserRepository opens (or creates) a file-backed repository in dir.
+// The latest snapshot is loaded and the write-ahead log is replayed on top of it.
+func NewFileUserRepository(dir string, logger *slog.Logger) (*FileUserRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	r := &FileUserRepository{
+		InMemoryUserRepository: NewInMemoryUserRepository(),
+		dir:                    dir,
+		logger:                 logger,
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	r.wal = wal
+
+	if err := r.replay(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	r.commit = r.appendLog
+	return r, nil
+}
+
+// loadSnapshot reads the snapshot file, if one exists, into memory.
+func (r *FileUserRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	for _, user := range snap.Users {
+		r.applyLocked(userMutation{Op: mutationPut, User: &user})
+	}
+	return nil
+}
+
+// replay applies every record in the write-ahead log to the in-memory state.
+// A torn record at the tail of the log (from a crash mid-write) is discarded;
+// a malformed record anywhere else is treated as corruption.
+func (r *FileUserRepository) replay() error {
+	reader := bufio.NewReader(r.wal)
+	var offset int64
+	var records int
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				r.logger.Warn("discarding torn write-ahead log record", "offset", offset)
+				if err := r.wal.Truncate(offset); err != nil {
+					return fmt.Errorf("truncate write-ahead log: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read write-ahead log: %w", err)
+		}
+
+		var m userMutation
+		if err := json.Unmarshal(bytes.TrimSpace(line), &m); err != nil {
+			return fmt.Errorf("corrupt write-ahead log record at offset %d: %w", offset, err)
+		}
+		r.applyLocked(m)
+		offset += int64(len(line))
+		records++
+	}
+
+	if _, err := r.wal.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek write-ahead log: %w", err)
+	}
+
+	r.logger.Info("user store loaded", "dir", r.dir, "users", len(r.users), "replayed", records)
+	return nil
+}
+
+// appendLog writes a mutation to the log and fsyncs it. It runs as the commit
+// hook of the embedded repository, so it is always called with the write lock held.
+func (r *FileUserRepository) appendLog(m userMutation) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("encode write-ahead log record: %w", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := r.wal.Write(data); err != nil {
+		return fmt.Errorf("append write-ahead log: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// Compact writes the current state to a new snapshot and truncates the log.
+// The snapshot is written to a temporary file and renamed into place, so a
+// crash at any point leaves either the old or the new snapshot intact. Replay
+// is idempotent, so a crash between the rename and the truncate is harmless.
+func (r *FileUserRepository) Compact() error {
+	r.compactMu.Lock()
+	defer r.compactMu.Unlock()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snap := snapshot{
+		CreatedAt: time.Now().UTC(),
+		Users:     make([]User, 0, len(r.users)),
+	}
+	for _, user := range r.users {
+		snap.Users = append(snap.Users, user)
+	}
+
+	if err := writeFileAtomic(filepath.Join(r.dir, snapshotFileName), snap); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := r.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate write-ahead log: %w", err)
+	}
+	if _, err := r.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek write-ahead log: %w", err)
+	}
+	if err := r.wal.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log: %w", err)
+	}
+
+	r.logger.Info("user store compacted", "users", len(snap.Users))
+	return nil
+}
+
+// RunCompaction compacts the store every interval until ctx is cancelled.
+func (r *FileUserRepository) RunCompaction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Compact(); err != nil {
+				r.logger.Error("user store compaction failed", "error", err)
+			}
+		}
+	}
+}
+
+// Close compacts the store one last time and closes the log.
+func (r *FileUserRepository) Close() error {
+	compactErr := r.Compact()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Reject any write that races with shutdown instead of touching a closed file.
+	r.commit = func(userMutation) error { return errors.New("user store is closed") }
+	return errors.Join(compactErr, r.wal.Close())
+}
+
+// Ping reports whether the write-ahead log is still open and accessible.
+func (r *FileUserRepository) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, err := r.wal.Stat(); err != nil {
+		return fmt.Errorf("stat write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic encodes v as JSON into a temporary file in the same directory,
+// fsyncs it, renames it over path and then fsyncs the directory.
+func writeFileAtomic(path string, v any) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename.
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: health.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Liveness and readiness probes. Readiness checks the repository
+// and is withdrawn as soon as shutdown begins, so orchestrators stop routing
+// traffic before in-flight requests are drained.
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds how long a readiness probe waits on the repository.
+const readinessTimeout = 2 * time.Second
+
+// Pinger is implemented by repositories that can report whether their backing
+// store is usable. Repositories without it are assumed to always be healthy.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// healthzHandler reports that the process is alive. It deliberately checks
+// nothing else, so a slow dependency never gets the process restarted.
+// GET /healthz
+// curl http://localhost:8080/healthz
+func (app *application) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{Status: "success", Message: "alive"})
+}
+
+// readyzHandler reports whether the server should receive traffic. It fails
+// once shutdown has begun and whenever the repository does not answer a ping.
+// GET /readyz
+// curl http://localhost:8080/readyz
+func (app *application) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if app.shuttingDown.Load() {
+		app.writeError(w, r, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+
+	if pinger, ok := app.users.(Pinger); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		if err := pinger.Ping(ctx); err != nil {
+			app.logger.WarnContext(r.Context(), "readiness check failed", "error", err)
+			app.writeError(w, r, http.StatusServiceUnavailable, "user repository is unavailable")
+			return
+		}
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{Status: "success", Message: "ready"})
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: i18n.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Localized validation messages. Translators for each supported
+// language are registered with the shared validator, and the best match for a
+// request is chosen from its Accept-Language header.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	de_translations "github.com/go-playground/validator/v10/translations/de"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+// translations holds one translator per supported language. English is the
+// fallback when nothing in Accept-Language is supported.
+var translations = newTranslations(validate)
+
+// newTranslations registers the default validation messages for every
+// supported language with v. It panics on failure, as that can only be caused
+// by a programming error.
+func newTranslations(v *validator.Validate) *ut.UniversalTranslator {
+	fallback := en.New()
+	uni := ut.New(fallback, fallback, de.New(), es.New(), fr.New())
+
+	for _, l := range []struct {
+		locale   string
+		register func(*validator.Validate, ut.Translator) error
+	}{
+		{"en", en_translations.RegisterDefaultTranslations},
+		{"de", de_translations.RegisterDefaultTranslations},
+		{"es", es_translations.RegisterDefaultTranslations},
+		{"fr", fr_translations.RegisterDefaultTranslations},
+	} {
+		trans, _ := uni.GetTranslator(l.locale)
+		if err := l.register(v, trans); err != nil {
+			panic(fmt.Sprintf("register %s validation translations: %v", l.locale, err))
+		}
+	}
+
+	return uni
+}
+
+// requestTranslator returns the translator that best matches the request's
+// Accept-Language header.
+func requestTranslator(r *http.Request) ut.Translator {
+	trans, _ := translations.FindTranslator(acceptedLanguages(r.Header.Get("Accept-Language"))...)
+	return trans
+}
+
+// acceptedLanguages parses an Accept-Language header into locale names in
+// order of preference. Each region-qualified tag ("de-AT") is followed by its
+// base language ("de") so it can fall back to the generic translation.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+	slices.SortStableFunc(tags, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	result := make([]string, 0, len(tags)*2)
+	for _, t := range tags {
+		tag := strings.ReplaceAll(t.tag, "-", "_")
+		result = append(result, tag)
+		if base, _, ok := strings.Cut(tag, "_"); ok {
+			result = append(result, base)
+		}
+	}
+	return result
+}
+
+// validationMessage returns the localized message for a failed validation,
+// falling back to a generic English message for tags without a translation.
+func validationMessage(fe validator.FieldError, trans ut.Translator) string {
+	if msg := fe.Translate(trans); msg != fe.Error() {
+		return msg
+	}
+	return fmt.Sprintf("%s failed the %q check", fe.Field(), fe.Tag())
+}
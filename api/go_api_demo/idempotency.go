@@ -0,0 +1,283 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: idempotency.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Idempotency-Key support. The first response to a keyed request
+// is stored and replayed for retries with the same key and body, so clients
+// can safely retry non-idempotent requests such as creating a user.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyKeyHeader carries the client-chosen key.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength bounds the size of stored keys.
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored with a record and replayed
+// with it. Headers set by outer middleware, such as rate limits, describe the
+// current request and are deliberately not replayed.
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Vary", "ETag", "Location"}
+
+// ErrIdempotencyKeyInUse is returned by IdempotencyStore.Begin when the key
+// already has a record. The existing record is returned alongside it.
+var ErrIdempotencyKeyInUse = errors.New("idempotency key already in use")
+
+// IdempotencyRecord is what is remembered about a keyed request.
+type IdempotencyRecord struct {
+	Key string
+	// RequestHash identifies the request the key was first used with.
+	RequestHash string
+	// Completed is false while the original request is still being handled.
+	Completed bool
+	Status    int
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// IdempotencyStore holds idempotency records. Like UserRepository, it can be
+// swapped for a shared store (e.g. Redis) when running several instances.
+type IdempotencyStore interface {
+	// Begin atomically reserves key for a request with the given hash. If the
+	// key is already reserved or completed, it returns the existing record and
+	// ErrIdempotencyKeyInUse.
+	Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (IdempotencyRecord, error)
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error
+	// Release removes a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// InMemoryIdempotencyStore is a thread-safe, in-memory IdempotencyStore.
+type InMemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	now     func() time.Time
+}
+
+// NewInMemoryIdempotencyStore creates an empty store.
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+// Begin reserves key unless a live record for it exists.
+func (s *InMemoryIdempotencyStore) Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return IdempotencyRecord{}, err
+	}
+
+	now := s.now()
+	if rec, ok := s.records[key]; ok && now.Before(rec.ExpiresAt) {
+		return rec, ErrIdempotencyKeyInUse
+	}
+
+	rec := IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: now.Add(ttl)}
+	s.records[key] = rec
+	return rec, nil
+}
+
+// Complete stores the response for key. The expiry set by Begin is kept.
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rec, ok := s.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.Completed = true
+	rec.Status = status
+	rec.Header = header.Clone()
+	rec.Body = bytes.Clone(body)
+	s.records[key] = rec
+	return nil
+}
+
+// Release forgets key.
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// evictExpired removes records whose TTL has passed.
+func (s *InMemoryIdempotencyStore) evictExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, rec := range s.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// RunEviction removes expired records every interval until ctx is cancelled.
+func (s *InMemoryIdempotencyStore) RunEviction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.evictExpired()
+		}
+	}
+}
+
+// responseCapture passes a response through to the client while keeping a
+// copy of its status and body.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (c *responseCapture) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// idempotent makes next safe to retry. Requests without an Idempotency-Key
+// header are passed through. Otherwise the first response for a key is
+// stored and replayed for later requests with the same key and body; reusing
+// a key with a different body is rejected with 422. Keys are scoped to the
+// principal and route, so clients cannot collide with each other.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			app.writeError(w, r, http.StatusBadRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, app.config.MaxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				app.writeError(w, r, http.StatusBadRequest, "body must not be larger than "+formatByteSize(app.config.MaxBodyBytes))
+				return
+			}
+			app.writeError(w, r, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		principal, _ := principalFromContext(r.Context())
+		scopedKey := principal.ID + "\x00" + r.Pattern + "\x00" + key
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		rec, err := app.idempotency.Begin(r.Context(), scopedKey, requestHash, app.config.IdempotencyTTL)
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyInUse):
+			app.replayIdempotent(w, r, rec, requestHash)
+			return
+		case err != nil:
+			app.writeRepositoryError(w, r, err)
+			return
+		}
+
+		// The request context may be cancelled by the time the handler
+		// returns, but the record must still be settled.
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+		defer func() {
+			// Server errors and panics are not remembered, so the client can
+			// retry them.
+			if !completed {
+				if err := app.idempotency.Release(ctx, scopedKey); err != nil {
+					app.logger.ErrorContext(r.Context(), "failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		capture := &responseCapture{ResponseWriter: w}
+		next(capture, r)
+		if capture.status == 0 || capture.status >= http.StatusInternalServerError {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range replayedHeaders {
+			if v := capture.Header().Values(name); len(v) > 0 {
+				header[name] = v
+			}
+		}
+		if err := app.idempotency.Complete(ctx, scopedKey, capture.status, header, capture.body.Bytes()); err != nil {
+			app.logger.ErrorContext(r.Context(), "failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// replayIdempotent answers a request whose key already has a record.
+func (app *application) replayIdempotent(w http.ResponseWriter, r *http.Request, rec IdempotencyRecord, requestHash string) {
+	switch {
+	case rec.RequestHash != requestHash:
+		app.writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key has already been used with a different request body")
+	case !rec.Completed:
+		w.Header().Set("Retry-After", "1")
+		app.writeError(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+	default:
+		for name, values := range rec.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(rec.Status)
+		if _, err := w.Write(rec.Body); err != nil {
+			app.logger.ErrorContext(r.Context(), "failed to replay idempotent response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: ids.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Collision-free, time-sortable resource IDs. UUIDv7 (RFC 9562)
+// and ULID generators share a monotonic source built on crypto/rand, so IDs
+// minted in the same millisecond still sort in creation order.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDGenerator mints identifiers for new resources. Implementations must be
+// safe for concurrent use and must never return the same ID twice.
+type IDGenerator interface {
+	NewID() string
+}
+
+// newIDGenerator returns the generator for format: "uuidv7" or "ulid".
+func newIDGenerator(format string) (IDGenerator, error) {
+	switch format {
+	case "uuidv7":
+		return NewUUIDv7Generator(), nil
+	case "ulid":
+		return NewULIDGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unknown ID format %q", format)
+	}
+}
+
+// monotonicSource produces a millisecond timestamp and a random component.
+// Within one millisecond, or if the clock steps backwards, the previous random
+// component is incremented instead of redrawn, so every value is strictly
+// greater than the last. If it overflows, the timestamp is advanced by 1ms.
+type monotonicSource struct {
+	// bits is the width of the random component, at most 80.
+	bits int
+	now  func() time.Time
+
+	mu     sync.Mutex
+	lastMS int64
+	random [10]byte
+}
+
+// next returns the timestamp and random component of the next ID. Only the
+// low bits of random are set.
+func (s *monotonicSource) next() (int64, [10]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.now().UnixMilli()
+	switch {
+	case ms > s.lastMS:
+		s.lastMS = ms
+		s.draw()
+	case !s.increment():
+		s.lastMS++
+		s.draw()
+	}
+	return s.lastMS, s.random
+}
+
+// draw fills the random component from crypto/rand.
+func (s *monotonicSource) draw() {
+	rand.Read(s.random[:]) // never returns an error
+	s.random[0] &= byte(0xff >> (80 - s.bits))
+}
+
+// increment adds one to the random component and reports false on overflow.
+func (s *monotonicSource) increment() bool {
+	for i := len(s.random) - 1; i >= 0; i-- {
+		s.random[i]++
+		if s.random[i] != 0 {
+			break
+		}
+	}
+	return s.random[0]>>(s.bits-72) == 0 && s.random != [10]byte{}
+}
+
+// UUIDv7Generator mints RFC 9562 version 7 UUIDs: a 48-bit Unix millisecond
+// timestamp followed by 74 random bits, in canonical lowercase form.
+type UUIDv7Generator struct {
+	src monotonicSource
+}
+
+// NewUUIDv7Generator creates a UUIDv7 generator.
+func NewUUIDv7Generator() *UUIDv7Generator {
+	return &UUIDv7Generator{src: monotonicSource{bits: 74, now: time.Now}}
+}
+
+// NewID returns a new UUIDv7.
+func (g *UUIDv7Generator) NewID() string {
+	ms, random := g.src.next()
+
+	// The 74 random bits are split into the 12-bit rand_a and 62-bit rand_b
+	// fields around the version and variant bits.
+	hi := uint64(binary.BigEndian.Uint16(random[:2]))
+	lo := binary.BigEndian.Uint64(random[2:])
+	randA := hi<<2 | lo>>62
+	randB := lo & (1<<62 - 1)
+
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[0:8], uint64(ms)<<16|0x7000|randA)
+	binary.BigEndian.PutUint64(u[8:16], 0x8000000000000000|randB)
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// ULIDGenerator mints ULIDs: a 48-bit Unix millisecond timestamp followed by
+// 80 random bits, encoded as 26 characters of Crockford base32.
+type ULIDGenerator struct {
+	src monotonicSource
+}
+
+// NewULIDGenerator creates a ULID generator.
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{src: monotonicSource{bits: 80, now: time.Now}}
+}
+
+// crockfordAlphabet is the base32 alphabet used by ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID.
+func (g *ULIDGenerator) NewID() string {
+	ms, random := g.src.next()
+
+	var u [16]byte
+	binary.BigEndian.PutUint16(u[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+	copy(u[6:], random[:])
+
+	// 26 characters hold 130 bits, so the first character carries only the
+	// top 3 bits of the 128-bit value.
+	hi := binary.BigEndian.Uint64(u[0:8])
+	lo := binary.BigEndian.Uint64(u[8:16])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// legacyIDPattern matches the timestamp-based IDs issued by earlier versions,
+// which may still be present in a file-backed store.
+var legacyIDPattern = regexp.MustCompile(`^(user|org)_[0-9]{1,20}$`)
+
+// validID reports whether id is in a format the server issues: a canonical
+// UUIDv7, a ULID, or a legacy timestamp ID. Checking the shape up front keeps
+// arbitrary input away from the repositories.
+func validID(id string) bool {
+	return isUUIDv7(id) || isULID(id) || legacyIDPattern.MatchString(id)
+}
+
+// isUUIDv7 reports whether id is a version 7 UUID in canonical lowercase form.
+func isUUIDv7(id string) bool {
+	if len(id) != 36 || id[14] != '7' || !strings.ContainsRune("89ab", rune(id[19])) {
+		return false
+	}
+	for i := range len(id) {
+		switch i {
+		case 8, 13, 18, 23:
+			if id[i] != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdef", rune(id[i])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isULID reports whether id is a ULID in canonical uppercase form.
+func isULID(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for i := range len(id) {
+		if !strings.ContainsRune(crockfordAlphabet, rune(id[i])) {
+			return false
+		}
+	}
+	return true
+}
@@ -11,17 +11,26 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -36,43 +45,219 @@ import (
 // Field tags for `json` and `validate` are used for serialization and validation.
 type User struct {
 	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId"`
 	CreatedAt time.Time `json:"createdAt"`
 	Name      string    `json:"name" validate:"required,min=2,max=100"`
 	Email     string    `json:"email" validate:"required,email"`
+	// Version is incremented by the repository on every successful write and
+	// is used for optimistic concurrency control.
+	Version int64 `json:"version"`
+	// DeletedAt is set when the user is soft-deleted. Deleted users are hidden
+	// from lookups and free their email address until they are restored.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
-// = a new validator instance.
-var validate = validator.New()
+// userInput is the request body for creating or replacing a user. Server-owned
+// fields such as ID and Version cannot be set by clients.
+type userInput struct {
+	Name  string `json:"name" validate:"required,min=2,max=100"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+// validate is the shared validator instance. It reports fields by their JSON
+// names so validation errors can be mapped directly to request fields.
+var validate = newValidator()
+
+// newValidator creates a validator that names fields after their `json` tag.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
 
 // =============================================================================
 // 2. REPOSITORY PATTERN (DATA LAYER)
 // =============================================================================
 
+// Sentinel errors returned by UserRepository implementations. Callers should
+// compare against them with errors.Is, as implementations may wrap them.
+var (
+	// ErrNotFound is returned when the requested user does not exist.
+	ErrNotFound = errors.New("user not found")
+	// ErrConflict is returned when a write conflicts with existing state.
+	ErrConflict = errors.New("conflict")
+	// ErrDuplicateEmail is returned when another user already has the email
+	// address. It wraps ErrConflict.
+	ErrDuplicateEmail = fmt.Errorf("%w: email address already in use", ErrConflict)
+	// ErrVersionConflict is returned when a conditional write names a version
+	// that no longer matches the stored user. It wraps ErrConflict.
+	ErrVersionConflict = fmt.Errorf("%w: user has been modified", ErrConflict)
+	// ErrInvalidCursor is returned by List when the cursor is malformed or was
+	// issued for a different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNotDeleted is returned by Restore when the user is not deleted. It
+	// wraps ErrConflict.
+	ErrNotDeleted = fmt.Errorf("%w: user is not deleted", ErrConflict)
+)
+
+// UserSort is the order in which List returns users. A leading "-" reverses it.
+type UserSort string
+
+const (
+	SortCreatedAtAsc  UserSort = "createdAt"
+	SortCreatedAtDesc UserSort = "-createdAt"
+	SortNameAsc       UserSort = "name"
+	SortNameDesc      UserSort = "-name"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListOptions controls filtering, ordering and pagination for List.
+type ListOptions struct {
+	// Limit is the maximum number of users to return. Zero means defaultListLimit.
+	Limit int
+	// Cursor is the opaque NextCursor from a previous page, or empty for the first page.
+	Cursor string
+	// Email, when set, matches users with exactly this email (case-insensitive).
+	Email string
+	// NamePrefix, when set, matches users whose name starts with it (case-insensitive).
+	NamePrefix string
+	// Sort is the result order. Empty means SortCreatedAtAsc.
+	Sort UserSort
+	// OrgID, when set, matches only users in that organization.
+	OrgID string
+	// IncludeDeleted also returns soft-deleted users.
+	IncludeDeleted bool
+}
+
+// PurgeOptions selects the soft-deleted users that Purge removes for good.
+type PurgeOptions struct {
+	// DeletedBefore matches users soft-deleted before this instant.
+	DeletedBefore time.Time
+	// OrgID, when set, matches only users in that organization.
+	OrgID string
+}
+
+// UserPage is a single page of List results. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
+
 // UserRepository defines the interface for user data storage.
 // This allows us to decouple the application from the specific database implementation.
 // All methods accept a context for cancellation and timeout propagation.
+// Email addresses are unique within an organization, compared case-insensitively.
+//
+// Implementations own User.Version: Create stores version 1 and every Update
+// increments it. Update and Delete are conditional when given a non-zero
+// version (user.Version for Update), failing with ErrVersionConflict if it
+// does not match the stored version.
+//
+// Delete is a soft delete: it sets User.DeletedAt, after which the user is
+// treated as not found by every method except GetDeleted, List with
+// IncludeDeleted, Restore and Purge. Restore is conditional like Delete.
 type UserRepository interface {
 	Create(ctx context.Context, user User) (User, error)
 	GetByID(ctx context.Context, id string) (User, error)
 	GetAll(ctx context.Context) ([]User, error)
+	List(ctx context.Context, opts ListOptions) (UserPage, error)
 	Update(ctx context.Context, id string, user User) (User, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, version int64) error
+	// GetDeleted returns a user only if it is soft-deleted.
+	GetDeleted(ctx context.Context, id string) (User, error)
+	// Restore undeletes a soft-deleted user.
+	Restore(ctx context.Context, id string, version int64) (User, error)
+	// Purge permanently removes the soft-deleted users matched by opts and
+	// returns how many were removed.
+	Purge(ctx context.Context, opts PurgeOptions) (int, error)
+}
+
+// repositoryUnwrapper is implemented by UserRepository decorators, so the
+// optional interfaces of the repository they wrap, such as Pinger, stay
+// reachable through them.
+type repositoryUnwrapper interface {
+	Unwrap() UserRepository
+}
+
+// repositoryAs returns the first repository in repo's chain of decorators
+// that implements T, as errors.As does for wrapped errors.
+func repositoryAs[T any](repo UserRepository) (T, bool) {
+	for repo != nil {
+		if t, ok := repo.(T); ok {
+			return t, true
+		}
+		u, ok := repo.(repositoryUnwrapper)
+		if !ok {
+			break
+		}
+		repo = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// userMutation describes a single change applied to a user store. It is the
+// unit of durability for repositories that persist writes (see FileUserRepository).
+type userMutation struct {
+	Op   string `json:"op"` // "put" or "delete"
+	User *User  `json:"user,omitempty"`
+	ID   string `json:"id,omitempty"`
 }
 
+const (
+	mutationPut    = "put"
+	mutationDelete = "delete"
+)
+
 // InMemoryUserRepository is a thread-safe, in-memory implementation of UserRepository.
 // It uses a sync.RWMutex to handle concurrent read/write operations safely.
 type InMemoryUserRepository struct {
-	mu    sync.RWMutex
-	users map[string]User
+	mu     sync.RWMutex
+	users  map[string]User
+	emails map[string]string // emailKey -> user ID
+
+	// commit, when set, is called with the write lock held after a mutation
+	// has been validated but before it is applied. Returning an error aborts
+	// the mutation, which lets a durable backend log the change first.
+	commit func(userMutation) error
 }
 
 // NewInMemoryUserRepository creates and returns a new InMemoryUserRepository.
 func NewInMemoryUserRepository() *InMemoryUserRepository {
 	return &InMemoryUserRepository{
-		users: make(map[string]User),
+		users:  make(map[string]User),
+		emails: make(map[string]string),
 	}
 }
 
+// normalizeEmail returns the canonical form used to compare email addresses.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailKey returns the key used to enforce email uniqueness. Emails are
+// unique per organization, so tenants cannot probe each other's users.
+func emailKey(user User) string {
+	return user.OrgID + "\x00" + normalizeEmail(user.Email)
+}
+
+// emailTakenLocked reports whether user's email belongs to a different user
+// in the same organization. The caller must hold the lock.
+func (r *InMemoryUserRepository) emailTakenLocked(user User) bool {
+	owner, ok := r.emails[emailKey(user)]
+	return ok && owner != user.ID
+}
+
 // Create adds a new user to the in-memory store.
 func (r *InMemoryUserRepository) Create(ctx context.Context, user User) (User, error) {
 	r.mu.Lock()
@@ -84,10 +269,16 @@ func (r *InMemoryUserRepository) Create(ctx context.Context, user User) (User, e
 	}
 
 	if _, exists := r.users[user.ID]; exists {
-		return User{}, fmt.Errorf("user with ID %s already exists", user.ID)
+		return User{}, fmt.Errorf("%w: user with ID %s already exists", ErrConflict, user.ID)
+	}
+	if r.emailTakenLocked(user) {
+		return User{}, ErrDuplicateEmail
 	}
 
-	r.users[user.ID] = user
+	user.Version = 1
+	if err := r.apply(userMutation{Op: mutationPut, User: &user}); err != nil {
+		return User{}, err
+	}
 	return user, nil
 }
 
@@ -101,8 +292,8 @@ func (r *InMemoryUserRepository) GetByID(ctx context.Context, id string) (User,
 	}
 
 	user, exists := r.users[id]
-	if !exists {
-		return User{}, fmt.Errorf("user not found")
+	if !exists || user.DeletedAt != nil {
+		return User{}, ErrNotFound
 	}
 	return user, nil
 }
@@ -118,11 +309,137 @@ func (r *InMemoryUserRepository) GetAll(ctx context.Context) ([]User, error) {
 
 	allUsers := make([]User, 0, len(r.users))
 	for _, user := range r.users {
-		allUsers = append(allUsers, user)
+		if user.DeletedAt == nil {
+			allUsers = append(allUsers, user)
+		}
 	}
 	return allUsers, nil
 }
 
+// List returns a filtered, ordered page of users. Pagination is keyset-based:
+// the cursor records the sort key of the last user returned, so pages stay
+// stable while users are created or deleted between requests.
+func (r *InMemoryUserRepository) List(ctx context.Context, opts ListOptions) (UserPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return UserPage{}, err
+	}
+
+	if opts.Sort == "" {
+		opts.Sort = SortCreatedAtAsc
+	}
+	compare, err := userComparator(opts.Sort)
+	if err != nil {
+		return UserPage{}, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	email := normalizeEmail(opts.Email)
+	namePrefix := strings.ToLower(opts.NamePrefix)
+	matched := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		if user.DeletedAt != nil && !opts.IncludeDeleted {
+			continue
+		}
+		if opts.OrgID != "" && user.OrgID != opts.OrgID {
+			continue
+		}
+		if email != "" && normalizeEmail(user.Email) != email {
+			continue
+		}
+		if namePrefix != "" && !strings.HasPrefix(strings.ToLower(user.Name), namePrefix) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+	slices.SortFunc(matched, compare)
+
+	start := 0
+	if opts.Cursor != "" {
+		after, err := decodeUserCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return UserPage{}, err
+		}
+		start = sort.Search(len(matched), func(i int) bool {
+			return compare(after, matched[i]) < 0
+		})
+	}
+	end := min(start+limit, len(matched))
+
+	page := UserPage{Users: matched[start:end]}
+	if end < len(matched) {
+		page.NextCursor = encodeUserCursor(page.Users[len(page.Users)-1], opts.Sort)
+	}
+	return page, nil
+}
+
+// userComparator returns an ordering function for s. Ties are broken by ID so
+// the order is total, which keyset pagination depends on.
+func userComparator(s UserSort) (func(a, b User) int, error) {
+	var compare func(a, b User) int
+	switch strings.TrimPrefix(string(s), "-") {
+	case string(SortCreatedAtAsc):
+		compare = func(a, b User) int {
+			return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+		}
+	case string(SortNameAsc):
+		compare = func(a, b User) int {
+			return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sort order %q", s)
+	}
+
+	if strings.HasPrefix(string(s), "-") {
+		return func(a, b User) int { return compare(b, a) }, nil
+	}
+	return compare, nil
+}
+
+// userCursor is the decoded form of an opaque pagination cursor. It carries
+// just enough of the last user to position the next page.
+type userCursor struct {
+	Sort      UserSort  `json:"s"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"c,omitzero"`
+	Name      string    `json:"n,omitempty"`
+}
+
+// encodeUserCursor builds the cursor that resumes listing after user.
+func encodeUserCursor(user User, s UserSort) string {
+	c := userCursor{Sort: s, ID: user.ID}
+	switch strings.TrimPrefix(string(s), "-") {
+	case string(SortCreatedAtAsc):
+		c.CreatedAt = user.CreatedAt
+	case string(SortNameAsc):
+		c.Name = user.Name
+	}
+	data, _ := json.Marshal(c) // Cannot fail: the struct has only plain fields.
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor parses a cursor and checks that it was issued for sort s.
+// The result is returned as a User so it can be fed to the comparator.
+func decodeUserCursor(cursor string, s UserSort) (User, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return User{}, ErrInvalidCursor
+	}
+	var c userCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return User{}, ErrInvalidCursor
+	}
+	if c.Sort != s {
+		return User{}, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+	return User{ID: c.ID, CreatedAt: c.CreatedAt, Name: c.Name}, nil
+}
+
 // Update modifies an existing user in the store.
 func (r *InMemoryUserRepository) Update(ctx context.Context, id string, user User) (User, error) {
 	r.mu.Lock()
@@ -132,17 +449,29 @@ func (r *InMemoryUserRepository) Update(ctx context.Context, id string, user Use
 		return User{}, err
 	}
 
-	if _, exists := r.users[id]; !exists {
-		return User{}, fmt.Errorf("user not found")
+	current, exists := r.users[id]
+	if !exists || current.DeletedAt != nil {
+		return User{}, ErrNotFound
+	}
+	if user.Version != 0 && user.Version != current.Version {
+		return User{}, ErrVersionConflict
 	}
-
 	user.ID = id // Ensure the ID remains the same
-	r.users[id] = user
+	user.DeletedAt = nil
+	if r.emailTakenLocked(user) {
+		return User{}, ErrDuplicateEmail
+	}
+
+	user.Version = current.Version + 1
+	if err := r.apply(userMutation{Op: mutationPut, User: &user}); err != nil {
+		return User{}, err
+	}
 	return user, nil
 }
 
-// Delete removes a user from the store.
-func (r *InMemoryUserRepository) Delete(ctx context.Context, id string) error {
+// Delete soft-deletes a user by stamping DeletedAt. A non-zero version makes
+// the delete conditional on the stored version.
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id string, version int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -150,29 +479,249 @@ func (r *InMemoryUserRepository) Delete(ctx context.Context, id string) error {
 		return err
 	}
 
-	if _, exists := r.users[id]; !exists {
-		return fmt.Errorf("user not found")
+	current, exists := r.users[id]
+	if !exists || current.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if version != 0 && version != current.Version {
+		return ErrVersionConflict
+	}
+
+	now := time.Now()
+	current.DeletedAt = &now
+	current.Version++
+	return r.apply(userMutation{Op: mutationPut, User: &current})
+}
+
+// GetDeleted retrieves a soft-deleted user by ID.
+func (r *InMemoryUserRepository) GetDeleted(ctx context.Context, id string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	user, exists := r.users[id]
+	switch {
+	case !exists:
+		return User{}, ErrNotFound
+	case user.DeletedAt == nil:
+		return User{}, ErrNotDeleted
+	}
+	return user, nil
+}
+
+// Restore clears DeletedAt on a soft-deleted user. A non-zero version makes
+// the restore conditional on the stored version. It fails with
+// ErrDuplicateEmail if the email address has been taken in the meantime.
+func (r *InMemoryUserRepository) Restore(ctx context.Context, id string, version int64) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	user, exists := r.users[id]
+	if !exists {
+		return User{}, ErrNotFound
+	}
+	if user.DeletedAt == nil {
+		return User{}, ErrNotDeleted
+	}
+	if version != 0 && version != user.Version {
+		return User{}, ErrVersionConflict
+	}
+	if r.emailTakenLocked(user) {
+		return User{}, ErrDuplicateEmail
+	}
+
+	user.DeletedAt = nil
+	user.Version++
+	if err := r.apply(userMutation{Op: mutationPut, User: &user}); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// Purge permanently removes users soft-deleted before opts.DeletedBefore.
+func (r *InMemoryUserRepository) Purge(ctx context.Context, opts PurgeOptions) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, user := range r.users {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if user.DeletedAt == nil || !user.DeletedAt.Before(opts.DeletedBefore) {
+			continue
+		}
+		if opts.OrgID != "" && user.OrgID != opts.OrgID {
+			continue
+		}
+		if err := r.apply(userMutation{Op: mutationDelete, ID: id}); err != nil {
+			return purged, err
+		}
+		purged++
 	}
-	delete(r.users, id)
+	return purged, nil
+}
+
+// Ping reports whether the store can serve requests. An in-memory store always
+// can, so only a cancelled context is reported.
+func (r *InMemoryUserRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// apply runs the commit hook, if any, and then applies the mutation to the map.
+// The caller must hold the write lock.
+func (r *InMemoryUserRepository) apply(m userMutation) error {
+	if r.commit != nil {
+		if err := r.commit(m); err != nil {
+			return err
+		}
+	}
+	r.applyLocked(m)
 	return nil
 }
 
+// applyLocked applies a mutation to the map without consulting the commit hook.
+// It is idempotent, which makes it safe to use when replaying a log. Only
+// users that are not soft-deleted hold an entry in the email index.
+func (r *InMemoryUserRepository) applyLocked(m userMutation) {
+	switch m.Op {
+	case mutationPut:
+		if m.User == nil {
+			return
+		}
+		if old, ok := r.users[m.User.ID]; ok {
+			r.unindexEmailLocked(old)
+		}
+		r.users[m.User.ID] = *m.User
+		if m.User.DeletedAt == nil {
+			r.emails[emailKey(*m.User)] = m.User.ID
+		}
+	case mutationDelete:
+		if old, ok := r.users[m.ID]; ok {
+			r.unindexEmailLocked(old)
+			delete(r.users, m.ID)
+		}
+	}
+}
+
+// unindexEmailLocked removes user's email from the index, unless the address
+// now belongs to someone else (possible once the user was soft-deleted).
+func (r *InMemoryUserRepository) unindexEmailLocked(user User) {
+	key := emailKey(user)
+	if r.emails[key] == user.ID {
+		delete(r.emails, key)
+	}
+}
+
 // =============================================================================
 // 3. APPLICATION & DEPENDENCY INJECTION
 // =============================================================================
 
 // Config holds all configuration for the application.
-// Values are read from environment variables.
+// Values are layered by loadConfig; see config.go.
 type Config struct {
 	Port string
+
+	// ReadTimeout, WriteTimeout and IdleTimeout configure the http.Server.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// TLSCertFile and TLSKeyFile enable HTTPS with a certificate that is
+	// reloaded when the files change. TLSSelfSigned instead generates one at
+	// startup, for development.
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSSelfSigned bool
+	// TLSMinVersion is the oldest accepted TLS version: "1.2" or "1.3".
+	TLSMinVersion string
+	// TLSCipherSuites is a comma-separated list of allowed TLS 1.2 cipher
+	// suites; empty selects Go's defaults.
+	TLSCipherSuites string
+	// HTTPRedirectPort, if set, serves plain HTTP redirects to HTTPS.
+	HTTPRedirectPort string
+
+	// CORSAllowedOrigins lists the origins, or "*", whose browser scripts may
+	// call the API. CORSMaxAge is how long a preflight response is cached.
+	CORSAllowedOrigins []string
+	CORSMaxAge         time.Duration
+	// Compression enables gzip and deflate responses.
+	Compression bool
+
+	// MaxBodyBytes limits the size of request bodies. Bulk imports have
+	// their own, larger limit.
+	MaxBodyBytes int64
+
+	// Storage selects the UserRepository backend: "memory" or "file".
+	Storage string
+	// DataDir is the directory used by the file backend.
+	DataDir string
+	// SnapshotInterval controls how often the file backend compacts its log.
+	SnapshotInterval time.Duration
+
+	// CacheSize is how many users the read-through cache holds; 0 disables
+	// it. Users are cached for CacheTTL, and lookups of missing users for
+	// CacheNegativeTTL.
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+
+	// APIKeys maps a principal name to its static API key.
+	APIKeys map[string]string
+	// JWTSecret is the HS256 key used to verify bearer tokens.
+	JWTSecret string
+
+	// SoftDeleteRetention is how long soft-deleted users are kept before a
+	// purge removes them for good.
+	SoftDeleteRetention time.Duration
+
+	// IDFormat selects the ID generator for new resources: "uuidv7" or "ulid".
+	IDFormat string
+
+	// IdempotencyTTL is how long a stored Idempotency-Key response is replayed.
+	IdempotencyTTL time.Duration
+
+	// ShutdownDelay is how long the server keeps serving, while reporting
+	// itself unready, between receiving a stop signal and starting to drain.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests, and then queued
+	// webhook deliveries, are waited for when stopping.
+	ShutdownTimeout time.Duration
+
+	// WebhookWorkers is the number of concurrent webhook deliveries.
+	WebhookWorkers int
+	// WebhookMaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered.
+	WebhookMaxAttempts int
 }
 
 // application is the central struct holding all application-wide dependencies,
 // such as the logger and data models (repositories).
 type application struct {
-	config Config
-	logger *slog.Logger
-	users  UserRepository
+	config  Config
+	logger  *slog.Logger
+	users   UserRepository
+	orgs    OrganizationRepository
+	auth    *authenticator
+	limiter *rateLimiter
+	metrics *appMetrics
+
+	idempotency IdempotencyStore
+	ids         IDGenerator
+	audit       AuditLog
+	webhooks    WebhookRepository
+	dispatcher  *webhookDispatcher
+	events      *eventBroker
+
+	// shuttingDown is set when a stop signal arrives and fails readiness checks.
+	shuttingDown atomic.Bool
 }
 
 // =============================================================================
@@ -186,29 +735,189 @@ type jsonResponse struct {
 	Data    any    `json:"data,omitempty"`
 }
 
-// errorResponse is a generic structure for sending JSON error messages.
-type errorResponse struct {
-	Error string `json:"error"`
+// listResponse is the envelope for paginated collections. NextCursor is always
+// present and is empty on the last page.
+type listResponse struct {
+	Status     string `json:"status"`
+	Data       any    `json:"data"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// problemContentType is the media type for RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problemDetails is an RFC 7807 error body. Errors is an extension member
+// carrying one entry per invalid field on validation failures.
+type problemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describes why a single request field failed validation.
+type fieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
 }
 
 // writeJSON is a helper for sending JSON-formatted responses.
-func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		app.logger.Error("failed to write JSON response", "error", err)
+		app.logger.ErrorContext(r.Context(), "failed to write JSON response", "error", err)
+	}
+}
+
+// writeError is a helper for sending problem+json error responses.
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	app.writeProblem(w, r, problemDetails{Status: status, Detail: detail})
+}
+
+// writeProblem fills in the defaults for a problem and writes it. Problems
+// without a specific type use "about:blank", whose title is the status text.
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, problem problemDetails) {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		app.logger.ErrorContext(r.Context(), "failed to write problem response", "error", err)
+	}
+}
+
+// writeBadRequest reports an error from reading the request. Validation
+// failures are expanded into one entry per invalid field, with messages in
+// the language negotiated from Accept-Language.
+func (app *application) writeBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		app.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trans := requestTranslator(r)
+	problem := problemDetails{
+		Status: http.StatusBadRequest,
+		Detail: "the request body failed validation",
+		Errors: make([]fieldError, 0, len(validationErrors)),
+	}
+	for _, fe := range validationErrors {
+		problem.Errors = append(problem.Errors, fieldError{
+			Field:   fe.Field(),
+			Tag:     fe.Tag(),
+			Message: validationMessage(fe, trans),
+		})
+	}
+	w.Header().Set("Content-Language", trans.Locale())
+	w.Header().Add("Vary", "Accept-Language")
+	app.writeProblem(w, r, problem)
+}
+
+// writeRepositoryError maps an error returned by a UserRepository to an HTTP
+// response. Unexpected errors are logged and reported as 500 without detail.
+func (app *application) writeRepositoryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		app.writeError(w, r, http.StatusNotFound, "user not found")
+	case errors.Is(err, ErrDuplicateEmail):
+		app.writeError(w, r, http.StatusConflict, "a user with this email address already exists")
+	case errors.Is(err, ErrNotDeleted):
+		app.writeError(w, r, http.StatusConflict, "the user is not deleted")
+	case errors.Is(err, ErrInvalidCursor):
+		app.writeError(w, r, http.StatusBadRequest, "cursor is invalid or does not match the requested sort order")
+	case errors.Is(err, ErrVersionConflict):
+		// Without If-Match the client did not ask for a precondition, so a
+		// concurrent modification is reported as a plain conflict.
+		if r.Header.Get("If-Match") != "" {
+			app.writeError(w, r, http.StatusPreconditionFailed, "the user has been modified since it was last retrieved")
+		} else {
+			app.writeError(w, r, http.StatusConflict, "the user was modified concurrently; retry the request")
+		}
+	case errors.Is(err, ErrConflict):
+		app.writeError(w, r, http.StatusConflict, "the request conflicts with the current state of the user")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// The client has gone away or the request ran out of time. This is
+		// not a server fault, so it is logged as a warning rather than an error.
+		app.logger.WarnContext(r.Context(), "request aborted", "method", r.Method, "path", r.URL.Path, "error", err)
+		app.writeError(w, r, http.StatusServiceUnavailable, "request was cancelled or timed out")
+	default:
+		app.logger.ErrorContext(r.Context(), "repository operation failed", "method", r.Method, "path", r.URL.Path, "error", err)
+		app.writeError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
 	}
 }
 
-// writeError is a helper for sending JSON-formatted error responses.
-func (app *application) writeError(w http.ResponseWriter, status int, message string) {
-	app.writeJSON(w, status, errorResponse{Error: message})
+// userETag returns the strong entity tag for a user. The version alone is
+// sufficient because it changes on every write to the user.
+func userETag(user User) string {
+	return `"` + strconv.FormatInt(user.Version, 10) + `"`
+}
+
+// etagListMatches reports whether an If-Match or If-None-Match header value
+// matches etag. If-Match requires strong comparison, so weak tags never match
+// it; If-None-Match uses weak comparison (RFC 9110, section 8.8.3.2).
+func etagListMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak, ok := strings.CutPrefix(candidate, "W/"); ok {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates the If-Match precondition against the current user.
+// It writes a 412 response and returns false if the precondition fails.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, current User) bool {
+	ifMatch := strings.Join(r.Header.Values("If-Match"), ",")
+	if ifMatch == "" || etagListMatches(ifMatch, userETag(current), true) {
+		return true
+	}
+	w.Header().Set("ETag", userETag(current))
+	app.writeError(w, r, http.StatusPreconditionFailed, "the user has been modified since it was last retrieved")
+	return false
 }
 
 // readJSON is a helper that decodes JSON from the request body and validates it.
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
-	// Limit request body size to 1MB.
-	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	if err := app.decodeJSON(w, r, dst); err != nil {
+		return err
+	}
+
+	// Perform validation on the decoded struct. The wrapped
+	// validator.ValidationErrors is expanded per field by writeBadRequest.
+	if err := validate.Struct(dst); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	return nil
+}
+
+// decodeJSON decodes a single JSON value from the request body into dst
+// without validating it.
+func (app *application) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.MaxBodyBytes)
 
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields() // Prevent unknown fields in the request body.
@@ -230,7 +939,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
 			return fmt.Errorf("body contains unknown key %s", fieldName)
 		case err.Error() == "http: request body too large":
-			return errors.New("body must not be larger than 1MB")
+			return fmt.Errorf("body must not be larger than %s", formatByteSize(app.config.MaxBodyBytes))
 		default:
 			return err
 		}
@@ -241,40 +950,282 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 		return errors.New("body must only contain a single JSON value")
 	}
 
-	// Perform validation on the decoded struct.
-	if err := validate.Struct(dst); err != nil {
-		// This can be further enhanced to return more detailed validation errors.
-		return fmt.Errorf("validation failed: %w", err)
+	return nil
+}
+
+// pathID returns the named path parameter if it is a well-formed ID. Otherwise
+// it writes a 400 response and returns false, so malformed IDs never reach a
+// repository.
+func (app *application) pathID(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	id := r.PathValue(name)
+	if !validID(id) {
+		app.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("path parameter %q is not a valid ID", name))
+		return "", false
 	}
+	return id, true
+}
 
-	return nil
+// requestMeta collects facts that inner middleware learns about a request,
+// such as the authenticated principal, so outer middleware can report them.
+type requestMeta struct {
+	// requestID identifies the request in logs and audit entries.
+	requestID string
+	// trace is the request's W3C Trace Context span.
+	trace     traceContext
+	principal string
+	// route is the matched ServeMux pattern, or "" if no route matched.
+	route string
 }
 
-// loggingMiddleware logs details of each incoming HTTP request.
-func (app *application) loggingMiddleware(next http.Handler) http.Handler {
+type requestMetaContextKey struct{}
+
+// requestMetaFromContext returns the request's metadata, or nil outside of
+// requestIDMiddleware.
+func requestMetaFromContext(ctx context.Context) *requestMeta {
+	meta, _ := ctx.Value(requestMetaContextKey{}).(*requestMeta)
+	return meta
+}
+
+// loggingMiddleware logs details of each incoming HTTP request and records
+// its metrics. It runs inside requestIDMiddleware, whose metadata it fills in.
+// routeOf resolves the route pattern up front, so requests rejected before
+// reaching the mux are still attributed to their route.
+func (app *application) loggingMiddleware(next http.Handler, routeOf func(*http.Request) string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		app.logger.Info("http request",
+		meta := requestMetaFromContext(r.Context())
+		meta.route = routeOf(r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		duration := time.Since(start)
+
+		// The request ID and trace are added by the logger's contextHandler.
+		app.metrics.observeRequest(r.Method, meta.route, rec.status, rec.bytes, duration)
+		app.logger.InfoContext(r.Context(), "http request",
 			"method", r.Method,
 			"path", r.URL.Path,
-			"duration", time.Since(start).String(),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"principal", meta.principal,
+			"duration", duration.String(),
 		)
 	})
 }
 
+// apiRoutes lists every route the server exposes. The same table drives both
+// the ServeMux and the generated OpenAPI document, so the two cannot drift.
+func (app *application) apiRoutes() []apiRoute {
+	ifMatch := apiParam{Name: "If-Match", In: "header", Description: "Only apply the change if the user's current ETag matches."}
+
+	return []apiRoute{
+		// User-related handlers under a versioned API path.
+		{
+			Pattern: "POST /api/v1/users", Handler: app.idempotent(app.createUserHandler),
+			OperationID: "createUser", Summary: "Create a user", Tag: "users",
+			Params: []apiParam{{Name: idempotencyKeyHeader, In: "header", Description: "Makes retries safe: a repeated request with the same key and body returns the original response."}},
+			Body:   userInput{}, Status: http.StatusCreated, Data: User{},
+		},
+		{
+			Pattern: "GET /api/v1/users", Handler: app.getAllUsersHandler,
+			OperationID: "listUsers", Summary: "List users", Tag: "users",
+			Params: []apiParam{
+				{Name: "limit", In: "query", Description: "Page size.", Schema: jsonSchema{"type": "integer", "minimum": 1, "maximum": maxListLimit, "default": defaultListLimit}},
+				{Name: "cursor", In: "query", Description: "The next_cursor from the previous page."},
+				{Name: "email", In: "query", Description: "Only users with this email address (case-insensitive)."},
+				{Name: "name_prefix", In: "query", Description: "Only users whose name starts with this prefix (case-insensitive)."},
+				{Name: "sort", In: "query", Description: "Sort order; a leading - reverses it.", Schema: jsonSchema{"type": "string", "enum": []UserSort{SortCreatedAtAsc, SortCreatedAtDesc, SortNameAsc, SortNameDesc}}},
+				{Name: "include_deleted", In: "query", Description: "Also return soft-deleted users (admins and owners only).", Schema: jsonSchema{"type": "boolean", "default": false}},
+			},
+			Data: User{}, List: true,
+		},
+		{
+			Pattern: "GET /api/v1/users/events", Handler: app.userEventsHandler,
+			OperationID: "streamUserEvents", Summary: "Stream user changes as Server-Sent Events", Tag: "users",
+			ResponseMediaType: "text/event-stream",
+			Params:            []apiParam{{Name: "Last-Event-ID", In: "header", Description: "Resume after this event, replaying any still buffered."}},
+		},
+		{
+			Pattern: "GET /api/v1/users/{id}", Handler: app.getUserHandler,
+			OperationID: "getUser", Summary: "Get a user", Tag: "users",
+			Params: []apiParam{{Name: "If-None-Match", In: "header", Description: "Return 304 if the user's current ETag matches."}},
+			Data:   User{},
+		},
+		{
+			Pattern: "PUT /api/v1/users/{id}", Handler: app.updateUserHandler,
+			OperationID: "replaceUser", Summary: "Replace a user", Tag: "users",
+			Params: []apiParam{ifMatch}, Body: userInput{}, Data: User{},
+		},
+		{
+			Pattern: "PATCH /api/v1/users/{id}", Handler: app.patchUserHandler,
+			OperationID: "patchUser", Summary: "Update a user with a JSON Merge Patch", Tag: "users",
+			Params: []apiParam{ifMatch}, Body: userInput{}, BodyMediaType: mergePatchContentType, Data: User{},
+		},
+		{
+			Pattern: "DELETE /api/v1/users/{id}", Handler: app.deleteUserHandler,
+			OperationID: "deleteUser", Summary: "Soft-delete a user", Tag: "users",
+			Params: []apiParam{ifMatch},
+		},
+		{
+			Pattern: "GET /api/v1/users/{id}/history", Handler: app.userHistoryHandler,
+			OperationID: "getUserHistory", Summary: "List a user's audit history, newest first", Tag: "users",
+			Params: []apiParam{
+				{Name: "limit", In: "query", Description: "Page size.", Schema: jsonSchema{"type": "integer", "minimum": 1, "maximum": maxListLimit, "default": defaultListLimit}},
+				{Name: "cursor", In: "query", Description: "The next_cursor from the previous page."},
+			},
+			Data: AuditEntry{}, List: true,
+		},
+		{
+			Pattern: "POST /api/v1/users/{id}/restore", Handler: app.restoreUserHandler,
+			OperationID: "restoreUser", Summary: "Restore a soft-deleted user", Tag: "users",
+			Params: []apiParam{ifMatch}, Data: User{},
+		},
+		{
+			Pattern: "POST /api/v1/users:purge", Handler: app.purgeUsersHandler,
+			OperationID: "purgeUsers", Summary: "Permanently remove users deleted longer ago than the retention window", Tag: "users",
+			Params: []apiParam{{Name: "older_than", In: "query", Description: "Retention window as a Go duration; defaults to the server's configured retention."}},
+			Data:   purgeResult{},
+		},
+		{
+			Pattern: "POST /api/v1/users:import", Handler: app.importUsersHandler,
+			OperationID: "importUsers", Summary: "Create users in bulk from NDJSON or CSV", Tag: "users",
+			Params: []apiParam{{Name: "dry_run", In: "query", Description: "Validate every row without creating any users.", Schema: jsonSchema{"type": "boolean", "default": false}}},
+			Body:   userInput{}, BodyMediaType: ndjsonContentType, Data: importResult{},
+		},
+		{
+			Pattern: "GET /api/v1/users:export", Handler: app.exportUsersHandler,
+			OperationID: "exportUsers", Summary: "Stream every user as NDJSON or CSV", Tag: "users",
+			ResponseMediaType: ndjsonContentType,
+			Params:            []apiParam{{Name: "format", In: "query", Description: "Output format; defaults to csv if Accept asks for text/csv, and ndjson otherwise.", Schema: jsonSchema{"type": "string", "enum": []string{"ndjson", "csv"}}}},
+		},
+
+		// Organizations and their memberships.
+		{
+			Pattern: "POST /api/v1/orgs", Handler: app.createOrgHandler,
+			OperationID: "createOrg", Summary: "Create an organization owned by the caller", Tag: "organizations",
+			Body: orgInput{}, Status: http.StatusCreated, Data: Organization{},
+		},
+		{
+			Pattern: "GET /api/v1/orgs", Handler: app.listOrgsHandler,
+			OperationID: "listOrgs", Summary: "List the caller's organizations", Tag: "organizations",
+			Data: Organization{}, List: true,
+		},
+		{
+			Pattern: "GET /api/v1/orgs/{orgID}", Handler: app.getOrgHandler,
+			OperationID: "getOrg", Summary: "Get an organization", Tag: "organizations",
+			Data: Organization{},
+		},
+		{
+			Pattern: "PUT /api/v1/orgs/{orgID}", Handler: app.updateOrgHandler,
+			OperationID: "updateOrg", Summary: "Rename an organization", Tag: "organizations",
+			Body: orgInput{}, Data: Organization{},
+		},
+		{
+			Pattern: "DELETE /api/v1/orgs/{orgID}", Handler: app.deleteOrgHandler,
+			OperationID: "deleteOrg", Summary: "Delete an empty organization", Tag: "organizations",
+		},
+		{
+			Pattern: "GET /api/v1/orgs/{orgID}/members", Handler: app.listMembersHandler,
+			OperationID: "listMembers", Summary: "List an organization's members", Tag: "organizations",
+			Data: Membership{}, List: true,
+		},
+		{
+			Pattern: "PUT /api/v1/orgs/{orgID}/members/{principalID}", Handler: app.putMemberHandler,
+			OperationID: "putMember", Summary: "Add a member or change its role", Tag: "organizations",
+			Body: memberInput{}, Data: Membership{},
+		},
+		{
+			Pattern: "DELETE /api/v1/orgs/{orgID}/members/{principalID}", Handler: app.deleteMemberHandler,
+			OperationID: "deleteMember", Summary: "Remove a member", Tag: "organizations",
+		},
+
+		// Webhook subscriptions.
+		{
+			Pattern: "POST /api/v1/webhooks", Handler: app.createWebhookHandler,
+			OperationID: "createWebhook", Summary: "Subscribe a URL to user events", Tag: "webhooks",
+			Body: webhookInput{}, Status: http.StatusCreated, Data: Webhook{},
+		},
+		{
+			Pattern: "GET /api/v1/webhooks", Handler: app.listWebhooksHandler,
+			OperationID: "listWebhooks", Summary: "List webhooks", Tag: "webhooks",
+			Data: Webhook{}, List: true,
+		},
+		{
+			Pattern: "GET /api/v1/webhooks/{id}", Handler: app.getWebhookHandler,
+			OperationID: "getWebhook", Summary: "Get a webhook", Tag: "webhooks",
+			Data: Webhook{},
+		},
+		{
+			Pattern: "DELETE /api/v1/webhooks/{id}", Handler: app.deleteWebhookHandler,
+			OperationID: "deleteWebhook", Summary: "Delete a webhook", Tag: "webhooks",
+		},
+		{
+			Pattern: "GET /api/v1/webhooks/{id}/dead-letters", Handler: app.listDeadLettersHandler,
+			OperationID: "listWebhookDeadLetters", Summary: "List deliveries that failed permanently", Tag: "webhooks",
+			Data: DeadLetter{}, List: true,
+		},
+
+		// API documentation.
+		{
+			Pattern: "GET /api/v1/openapi.json", Handler: app.openAPIHandler,
+			OperationID: "getOpenAPI", Summary: "This OpenAPI document", Tag: "meta",
+			Public: true, ResponseMediaType: "application/json",
+		},
+		{
+			Pattern: "GET /api/v1/docs", Handler: app.docsHandler,
+			OperationID: "getDocs", Summary: "Interactive API documentation", Tag: "meta",
+			Public: true, ResponseMediaType: "text/html",
+		},
+
+		// Operational endpoints.
+		{
+			Pattern: "GET /metrics", Handler: app.metricsHandler,
+			OperationID: "getMetrics", Summary: "Prometheus metrics", Tag: "operations",
+			Public: true, ResponseMediaType: "text/plain",
+		},
+		{
+			Pattern: "GET /healthz", Handler: app.healthzHandler,
+			OperationID: "getLiveness", Summary: "Liveness probe", Tag: "operations",
+			Public: true,
+		},
+		{
+			Pattern: "GET /readyz", Handler: app.readyzHandler,
+			OperationID: "getReadiness", Summary: "Readiness probe", Tag: "operations",
+			Public: true,
+		},
+	}
+}
+
 // routes sets up all the application routes and applies middleware.
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	// Registering user-related handlers under a versioned API path.
-	mux.HandleFunc("POST /api/v1/users", app.createUserHandler)
-	mux.HandleFunc("GET /api/v1/users", app.getAllUsersHandler)
-	mux.HandleFunc("GET /api/v1/users/{id}", app.getUserHandler)
-	mux.HandleFunc("PUT /api/v1/users/{id}", app.updateUserHandler)
-	mux.HandleFunc("DELETE /api/v1/users/{id}", app.deleteUserHandler)
+	// Route patterns marked Public are served without authentication.
+	public := map[string]bool{}
+	for _, route := range app.apiRoutes() {
+		mux.HandleFunc(route.Pattern, route.Handler)
+		public[route.Pattern] = route.Public
+	}
+
+	routeOf := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	isPublic := func(r *http.Request) bool {
+		return public[routeOf(r)]
+	}
 
-	return app.loggingMiddleware(mux)
+	// CORS runs before recovery so a recovered error keeps its CORS headers,
+	// and before authentication because preflights carry no credentials.
+	// Rate limiting runs after authentication so budgets can be keyed by principal.
+	return chain(mux,
+		app.requestIDMiddleware,
+		func(next http.Handler) http.Handler { return app.loggingMiddleware(next, routeOf) },
+		app.corsMiddleware,
+		app.recoverMiddleware,
+		app.compressMiddleware,
+		func(next http.Handler) http.Handler { return app.authMiddleware(next, isPublic) },
+		app.rateLimitMiddleware,
+	)
 }
 
 // --- CRUD Handlers ---
@@ -286,30 +1237,33 @@ func (app *application) routes() http.Handler {
 //	 -d '{"name": "dunamismax", "email": "dev@example.com"}' \
 //	 http://localhost:8080/api/v1/users
 func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Name  string `json:"name" validate:"required,min=2,max=100"`
-		Email string `json:"email" validate:"required,email"`
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
 	}
 
+	var input userInput
+
 	if err := app.readJSON(w, r, &input); err != nil {
-		app.writeError(w, http.StatusBadRequest, err.Error())
+		app.writeBadRequest(w, r, err)
 		return
 	}
 
 	user := User{
-		ID:        fmt.Sprintf("user_%d", time.Now().UnixNano()), // Simple unique ID
+		ID:        app.ids.NewID(),
 		CreatedAt: time.Now(),
 		Name:      input.Name,
 		Email:     input.Email,
 	}
 
-	createdUser, err := app.users.Create(r.Context(), user)
+	createdUser, err := users.Create(r.Context(), user)
 	if err != nil {
-		app.writeError(w, http.StatusInternalServerError, "failed to create user")
+		app.writeRepositoryError(w, r, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusCreated, jsonResponse{
+	w.Header().Set("ETag", userETag(createdUser))
+	app.writeJSON(w, r, http.StatusCreated, jsonResponse{
 		Status:  "success",
 		Message: "User created successfully",
 		Data:    createdUser,
@@ -320,36 +1274,105 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 // GET /api/v1/users/{id}
 // curl http://localhost:8080/api/v1/users/{id}
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
+	users, ok := app.scopedUsers(w, r, permUsersRead)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
 
-	user, err := app.users.GetByID(r.Context(), id)
+	user, err := users.GetByID(r.Context(), id)
 	if err != nil {
-		app.writeError(w, http.StatusNotFound, "user not found")
+		app.writeRepositoryError(w, r, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusOK, jsonResponse{
+	etag := userETag(user)
+	w.Header().Set("ETag", etag)
+	if inm := strings.Join(r.Header.Values("If-None-Match"), ","); inm != "" && etagListMatches(inm, etag, false) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
 		Status: "success",
 		Data:   user,
 	})
 }
 
-// getAllUsersHandler retrieves all users.
-// GET /api/v1/users
-// curl http://localhost:8080/api/v1/users
+// getAllUsersHandler retrieves a page of users.
+// GET /api/v1/users?limit=20&cursor=...&email=...&name_prefix=...&sort=createdAt|-createdAt|name|-name&include_deleted=true
+// curl "http://localhost:8080/api/v1/users?limit=2&sort=-createdAt"
 func (app *application) getAllUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := app.users.GetAll(r.Context())
+	users, ok := app.scopedUsers(w, r, permUsersRead)
+	if !ok {
+		return
+	}
+
+	opts, err := readListOptions(r)
 	if err != nil {
-		app.writeError(w, http.StatusInternalServerError, "could not retrieve users")
+		app.writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	if opts.IncludeDeleted {
+		if _, ok := app.authorize(w, r, permUsersReadDeleted); !ok {
+			return
+		}
+	}
 
-	app.writeJSON(w, http.StatusOK, jsonResponse{
-		Status: "success",
-		Data:   users,
+	page, err := users.List(r.Context(), opts)
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status:     "success",
+		Data:       page.Users,
+		NextCursor: page.NextCursor,
 	})
 }
 
+// readListOptions parses and validates the query parameters for listing users.
+func readListOptions(r *http.Request) (ListOptions, error) {
+	q := r.URL.Query()
+	opts := ListOptions{
+		Limit:      defaultListLimit,
+		Cursor:     q.Get("cursor"),
+		Email:      q.Get("email"),
+		NamePrefix: q.Get("name_prefix"),
+		Sort:       UserSort(q.Get("sort")),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return ListOptions{}, fmt.Errorf("limit must be an integer between 1 and %d", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	switch opts.Sort {
+	case "":
+		opts.Sort = SortCreatedAtAsc
+	case SortCreatedAtAsc, SortCreatedAtDesc, SortNameAsc, SortNameDesc:
+	default:
+		return ListOptions{}, errors.New("sort must be one of createdAt, -createdAt, name, -name")
+	}
+
+	if v := q.Get("include_deleted"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return ListOptions{}, errors.New("include_deleted must be true or false")
+		}
+		opts.IncludeDeleted = include
+	}
+
+	return opts, nil
+}
+
 // updateUserHandler handles updating an existing user.
 // PUT /api/v1/users/{id}
 //
@@ -357,92 +1380,430 @@ func (app *application) getAllUsersHandler(w http.ResponseWriter, r *http.Reques
 //	 -d '{"name": "dunamismax_v2", "email": "dev_v2@example.com"}' \
 //	 http://localhost:8080/api/v1/users/{id}
 func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-
-	var input struct {
-		Name  string `json:"name" validate:"required,min=2,max=100"`
-		Email string `json:"email" validate:"required,email"`
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
 	}
 
+	var input userInput
+
 	if err := app.readJSON(w, r, &input); err != nil {
-		app.writeError(w, http.StatusBadRequest, err.Error())
+		app.writeBadRequest(w, r, err)
 		return
 	}
 
 	// Fetch existing user to update. In a real app, you might only update certain fields.
-	existingUser, err := app.users.GetByID(r.Context(), id)
+	existingUser, err := users.GetByID(r.Context(), id)
 	if err != nil {
-		app.writeError(w, http.StatusNotFound, "user not found")
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+	if !app.checkIfMatch(w, r, existingUser) {
 		return
 	}
 
 	existingUser.Name = input.Name
 	existingUser.Email = input.Email
 
-	updatedUser, err := app.users.Update(r.Context(), id, existingUser)
+	// existingUser.Version makes the update conditional on nothing having
+	// changed since it was read above.
+	updatedUser, err := users.Update(r.Context(), id, existingUser)
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(updatedUser))
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "User updated successfully",
+		Data:    updatedUser,
+	})
+}
+
+// mergePatchContentType is the media type for JSON Merge Patch (RFC 7396).
+const mergePatchContentType = "application/merge-patch+json"
+
+// User fields a merge patch may change, and those it must never touch.
+var (
+	patchableUserFields = map[string]bool{"name": true, "email": true}
+	immutableUserFields = map[string]bool{"id": true, "orgId": true, "createdAt": true, "version": true}
+)
+
+// patchUserHandler applies a JSON Merge Patch to an existing user. Only the
+// fields present in the patch are changed; the merged user is then validated
+// as a whole.
+// PATCH /api/v1/users/{id}
+//
+//	curl -X PATCH -H "Content-Type: application/merge-patch+json" \
+//	 -d '{"name": "dunamismax_v3"}' \
+//	 http://localhost:8080/api/v1/users/{id}
+func (app *application) patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchContentType {
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		app.writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType)
+		return
+	}
+
+	var body any
+	if err := app.decodeJSON(w, r, &body); err != nil {
+		app.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	patch, ok := body.(map[string]any)
+	if !ok {
+		app.writeError(w, r, http.StatusBadRequest, "body must be a JSON object")
+		return
+	}
+	for field := range patch {
+		switch {
+		case immutableUserFields[field]:
+			app.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("field %q is immutable", field))
+			return
+		case !patchableUserFields[field]:
+			app.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("body contains unknown key %q", field))
+			return
+		}
+	}
+
+	existingUser, err := users.GetByID(r.Context(), id)
 	if err != nil {
-		app.writeError(w, http.StatusInternalServerError, "failed to update user")
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+	if !app.checkIfMatch(w, r, existingUser) {
 		return
 	}
 
-	app.writeJSON(w, http.StatusOK, jsonResponse{
+	patchedUser, err := applyMergePatch(existingUser, patch)
+	if err != nil {
+		app.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validate.Struct(patchedUser); err != nil {
+		app.writeBadRequest(w, r, fmt.Errorf("validation failed: %w", err))
+		return
+	}
+
+	updatedUser, err := users.Update(r.Context(), id, patchedUser)
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(updatedUser))
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
 		Status:  "success",
 		Message: "User updated successfully",
 		Data:    updatedUser,
 	})
 }
 
+// applyMergePatch applies an RFC 7396 merge patch to a user by round-tripping
+// it through its JSON representation, so field names match the wire format.
+func applyMergePatch(user User, patch map[string]any) (User, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return User{}, err
+	}
+	var target map[string]any
+	if err := json.Unmarshal(data, &target); err != nil {
+		return User{}, err
+	}
+
+	data, err = json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return User{}, err
+	}
+	var patched User
+	if err := json.Unmarshal(data, &patched); err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		if errors.As(err, &unmarshalTypeError) {
+			return User{}, fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return User{}, err
+	}
+	return patched, nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7396: null removes a
+// member, objects are merged recursively and any other value replaces the target.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any)
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
 // deleteUserHandler handles deleting a user.
 // DELETE /api/v1/users/{id}
-// curl -X DELETE http://localhost:8080/api/v1/users/{id}
+// curl -X DELETE -H 'If-Match: "1"' http://localhost:8080/api/v1/users/{id}
 func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
 
-	if err := app.users.Delete(r.Context(), id); err != nil {
-		app.writeError(w, http.StatusNotFound, "user not found")
+	// An unconditional delete needs no read; a conditional one checks the
+	// precondition and then pins the delete to the version it checked.
+	var version int64
+	if r.Header.Get("If-Match") != "" {
+		existingUser, err := users.GetByID(r.Context(), id)
+		if err != nil {
+			app.writeRepositoryError(w, r, err)
+			return
+		}
+		if !app.checkIfMatch(w, r, existingUser) {
+			return
+		}
+		version = existingUser.Version
+	}
+
+	if err := users.Delete(r.Context(), id, version); err != nil {
+		app.writeRepositoryError(w, r, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusOK, jsonResponse{
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
 		Status:  "success",
 		Message: "User deleted successfully",
 	})
 }
 
+// restoreUserHandler undeletes a soft-deleted user.
+// POST /api/v1/users/{id}/restore
+// curl -X POST http://localhost:8080/api/v1/users/{id}/restore
+func (app *application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	users, ok := app.scopedUsers(w, r, permUsersWrite)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var version int64
+	if r.Header.Get("If-Match") != "" {
+		deletedUser, err := users.GetDeleted(r.Context(), id)
+		if err != nil {
+			app.writeRepositoryError(w, r, err)
+			return
+		}
+		if !app.checkIfMatch(w, r, deletedUser) {
+			return
+		}
+		version = deletedUser.Version
+	}
+
+	restoredUser, err := users.Restore(r.Context(), id, version)
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(restoredUser))
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "User restored successfully",
+		Data:    restoredUser,
+	})
+}
+
+// purgeResult reports how many users a purge removed.
+type purgeResult struct {
+	Purged int `json:"purged"`
+}
+
+// purgeUsersHandler permanently removes users that were soft-deleted longer
+// ago than the retention window. The window defaults to the configured
+// retention and can be overridden with older_than (e.g. "0s" for all).
+// POST /api/v1/users:purge?older_than=720h
+// curl -X POST "http://localhost:8080/api/v1/users:purge?older_than=720h"
+func (app *application) purgeUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, ok := app.scopedUsers(w, r, permUsersPurge)
+	if !ok {
+		return
+	}
+
+	retention := app.config.SoftDeleteRetention
+	if v := r.URL.Query().Get("older_than"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			app.writeError(w, r, http.StatusBadRequest, "older_than must be a non-negative duration such as 720h")
+			return
+		}
+		retention = d
+	}
+
+	purged, err := users.Purge(r.Context(), PurgeOptions{DeletedBefore: time.Now().Add(-retention)})
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Purged %d deleted users", purged),
+		Data:    purgeResult{Purged: purged},
+	})
+}
+
 // =============================================================================
 // 5. MAIN APPLICATION ENTRYPOINT
 // =============================================================================
 
 func main() {
 	// 1. Initialize logger.
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// Records logged with a request's context carry its request and trace IDs.
+	logger := slog.New(newContextHandler(slog.NewJSONHandler(os.Stdout, nil)))
 
 	// 2. Load configuration.
-	cfg := Config{
-		Port: os.Getenv("API_PORT"),
+	cfg, printConfig, err := loadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	if cfg.Port == "" {
-		cfg.Port = "8080"
+	if printConfig {
+		if err := writeConfig(os.Stdout, cfg); err != nil {
+			logger.Error("failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			logger.Error("invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	ids, err := newIDGenerator(cfg.IDFormat)
+	if err != nil {
+		logger.Error("invalid ID format", "error", err)
+		os.Exit(1)
+	}
+	auth, err := newAuthenticator(cfg.APIKeys, cfg.JWTSecret)
+	if err != nil {
+		logger.Error("failed to configure authentication", "error", err)
+		os.Exit(1)
 	}
 
 	// 3. Initialize dependencies (database repository).
-	userRepo := NewInMemoryUserRepository()
+	userRepo, closeRepo, err := newUserRepository(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize user repository", "error", err)
+		os.Exit(1)
+	}
 
 	// 4. Create the main application struct with all dependencies.
+	metrics := newAppMetrics()
+	idempotency := NewInMemoryIdempotencyStore()
+	audit := NewInMemoryAuditLog()
+	webhooks := NewInMemoryWebhookRepository()
+	dispatcher := newWebhookDispatcher(webhooks, ids, logger, cfg.WebhookMaxAttempts)
+	dispatcher.Start(cfg.WebhookWorkers)
+
+	// Decorators apply from the inside out: reads by ID are cached, writes
+	// are audited, then published as events, and every call is measured.
+	if cfg.CacheSize > 0 {
+		userRepo = NewCachingUserRepository(userRepo, cfg.CacheSize, cfg.CacheTTL, cfg.CacheNegativeTTL, metrics)
+	}
+	users := NewAuditedUserRepository(userRepo, audit, ids, logger)
+	broker := newEventBroker()
+	users = NewEventingUserRepository(users, ids, logger, dispatcher, broker)
+	users = NewInstrumentedUserRepository(users, metrics)
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		users:  userRepo,
+		config:  cfg,
+		logger:  logger,
+		users:   users,
+		orgs:    NewInMemoryOrganizationRepository(),
+		auth:    auth,
+		limiter: newRateLimiter(defaultRateLimitRules),
+		metrics: metrics,
+
+		idempotency: idempotency,
+		ids:         ids,
+		audit:       audit,
+		webhooks:    webhooks,
+		dispatcher:  dispatcher,
+		events:      broker,
 	}
 
+	// Background workers run until the server has shut down.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go app.limiter.run(bgCtx)
+	go idempotency.RunEviction(bgCtx, time.Minute)
+
 	// 5. Configure the HTTP server.
+	tlsConfig, err := newTLSConfig(cfg, logger)
+	if err != nil {
+		logger.Error("failed to configure TLS", "error", err)
+		os.Exit(1)
+	}
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      app.routes(), // Use the new router
+		TLSConfig:    tlsConfig,
 		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	// Event streams outlive WriteTimeout and must be ended for Shutdown to finish.
+	srv.RegisterOnShutdown(broker.Close)
+
+	// Plain HTTP on the redirect port only ever points clients at HTTPS.
+	var redirectSrv *http.Server
+	if cfg.HTTPRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:         ":" + cfg.HTTPRedirectPort,
+			Handler:      redirectToHTTPS(cfg.Port),
+			ErrorLog:     srv.ErrorLog,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		}
+		go func() {
+			logger.Info("redirecting HTTP to HTTPS", "address", redirectSrv.Addr)
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("redirect server failed to start", "error", err)
+				os.Exit(1)
+			}
+		}()
 	}
 
 	// 6. Run the server in a goroutine for graceful shutdown.
@@ -454,9 +1815,22 @@ func main() {
 
 		logger.Info("shutdown signal received", "signal", sig.String())
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		// Fail readiness first and keep serving for a while, so load balancers
+		// stop sending new traffic before the listener is closed.
+		app.shuttingDown.Store(true)
+		if cfg.ShutdownDelay > 0 {
+			logger.Info("waiting before draining connections", "delay", cfg.ShutdownDelay.String())
+			time.Sleep(cfg.ShutdownDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 		defer cancel()
 
+		if redirectSrv != nil {
+			if err := redirectSrv.Shutdown(ctx); err != nil {
+				logger.Warn("redirect server shutdown failed", "error", err)
+			}
+		}
 		if err := srv.Shutdown(ctx); err != nil {
 			shutdownError <- err
 		}
@@ -465,11 +1839,17 @@ func main() {
 		shutdownError <- nil
 	}()
 
-	logger.Info("server starting", "address", srv.Addr)
+	logger.Info("server starting", "address", srv.Addr, "tls", tlsConfig != nil)
 
 	// Start the server. If it fails for reasons other than a clean shutdown,
-	// log the error.
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	// log the error. The certificate comes from TLSConfig, so no files are
+	// passed to ListenAndServeTLS.
+	if tlsConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Error("server failed to start", "error", err)
 		os.Exit(1)
 	}
@@ -479,4 +1859,64 @@ func main() {
 		logger.Error("server shutdown failed", "error", err)
 		os.Exit(1)
 	}
+
+	// No more events can be published, so deliver what is left in the
+	// webhook queue before exiting.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelDrain()
+	if err := dispatcher.Close(drainCtx); err != nil {
+		logger.Warn("webhook queue did not drain in time; remaining deliveries were dead-lettered", "error", err)
+	}
+
+	// Flush the repository only after in-flight requests have drained.
+	if err := closeRepo(); err != nil {
+		logger.Error("failed to close user repository", "error", err)
+		os.Exit(1)
+	}
+}
+
+// parseAPIKeys parses a comma-separated list of name:key pairs.
+func parseAPIKeys(s string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if s == "" {
+		return keys, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("entry %q is not of the form name:key", pair)
+		}
+		if _, dup := keys[name]; dup {
+			return nil, fmt.Errorf("duplicate API key name %q", name)
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// newUserRepository builds the UserRepository selected by cfg.Storage. The
+// returned close function stops any background work and flushes the store.
+func newUserRepository(cfg Config, logger *slog.Logger) (UserRepository, func() error, error) {
+	switch cfg.Storage {
+	case "memory":
+		return NewInMemoryUserRepository(), func() error { return nil }, nil
+	case "file":
+		repo, err := NewFileUserRepository(cfg.DataDir, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			repo.RunCompaction(ctx, cfg.SnapshotInterval)
+		}()
+		return repo, func() error {
+			cancel()
+			<-done
+			return repo.Close()
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
 }
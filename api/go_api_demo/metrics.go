@@ -0,0 +1,485 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: metrics.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Request and repository instrumentation exposed at /metrics in
+// the Prometheus text exposition format. Counters, histograms and gauges are
+// implemented here so the server needs no metrics client library.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// RESPONSE RECORDING
+// =============================================================================
+
+// statusRecorder wraps a ResponseWriter to capture the status code and the
+// number of body bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status before passing it on.
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body size, defaulting the status to 200 like net/http does.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (rec *statusRecorder) Flush() {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// statusClass groups a status code as "2xx", "4xx" and so on.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// =============================================================================
+// METRIC TYPES
+// =============================================================================
+
+// collector is anything that can render itself in the text exposition format.
+type collector interface {
+	collect(w io.Writer)
+}
+
+// metricsRegistry holds every collector exposed at /metrics.
+type metricsRegistry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// register adds c to the registry and returns it for convenience.
+func register[C collector](reg *metricsRegistry, c C) C {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.collectors = append(reg.collectors, c)
+	return c
+}
+
+// writeTo renders all registered metrics.
+func (reg *metricsRegistry) writeTo(w io.Writer) {
+	reg.mu.Lock()
+	collectors := slices.Clone(reg.collectors)
+	reg.mu.Unlock()
+
+	for _, c := range collectors {
+		c.collect(w)
+	}
+}
+
+// metricDesc is the name, help text and label names shared by a metric family.
+type metricDesc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines for the family.
+func (d metricDesc) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, typ)
+}
+
+// labelKey joins label values into a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels renders name="value" pairs, optionally followed by extra pairs.
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extra[i], labelEscaper.Replace(extra[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelEscaper applies the escapes the text format allows in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat renders a sample value the way Prometheus expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// counterVec is a family of monotonically increasing counters.
+type counterVec struct {
+	metricDesc
+	mu     sync.Mutex
+	values map[string]*labeledValue
+}
+
+// labeledValue is a sample together with the label values that identify it.
+type labeledValue struct {
+	labels []string
+	value  float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		metricDesc: metricDesc{name: name, help: help, labels: labels},
+		values:     make(map[string]*labeledValue),
+	}
+}
+
+// add increments the counter identified by labelValues by v.
+func (c *counterVec) add(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lv, ok := c.values[key]
+	if !ok {
+		lv = &labeledValue{labels: slices.Clone(labelValues)}
+		c.values[key] = lv
+	}
+	lv.value += v
+}
+
+// inc increments the counter identified by labelValues by one.
+func (c *counterVec) inc(labelValues ...string) {
+	c.add(1, labelValues...)
+}
+
+func (c *counterVec) collect(w io.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		lv := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, lv.labels), formatFloat(lv.value))
+	}
+}
+
+// histogramVec is a family of histograms sharing the same bucket bounds.
+type histogramVec struct {
+	metricDesc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue is the state of one labelled histogram. counts[i] is the
+// number of observations that fell into bucket i (not cumulative).
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Bucket bounds, in seconds, for HTTP requests and for repository calls.
+var (
+	httpDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	repoDurationBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+)
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		metricDesc: metricDesc{name: name, help: help, labels: labels},
+		buckets:    buckets,
+		values:     make(map[string]*histogramValue),
+	}
+}
+
+// observe records v in the histogram identified by labelValues.
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *histogramVec) collect(w io.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hv.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hv.labels, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, hv.labels), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, hv.labels), hv.count)
+	}
+}
+
+// gaugeFunc is a gauge whose value is computed at scrape time. If fn returns
+// an error the sample is omitted.
+type gaugeFunc struct {
+	metricDesc
+	fn func() (float64, error)
+}
+
+func newGaugeFunc(name, help string, fn func() (float64, error)) *gaugeFunc {
+	return &gaugeFunc{metricDesc: metricDesc{name: name, help: help}, fn: fn}
+}
+
+func (g *gaugeFunc) collect(w io.Writer) {
+	v, err := g.fn()
+	if err != nil {
+		return
+	}
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(v))
+}
+
+// sortedKeys returns the keys of m in order, so output is stable between scrapes.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// =============================================================================
+// APPLICATION METRICS
+// =============================================================================
+
+// appMetrics are the metrics recorded by the server.
+type appMetrics struct {
+	registry *metricsRegistry
+
+	httpRequests      *counterVec
+	httpDuration      *histogramVec
+	httpResponseBytes *counterVec
+	repoDuration      *histogramVec
+}
+
+// newAppMetrics creates and registers the server's metrics.
+func newAppMetrics() *appMetrics {
+	reg := &metricsRegistry{}
+	return &appMetrics{
+		registry: reg,
+		httpRequests: register(reg, newCounterVec("http_requests_total",
+			"Total HTTP requests by method, route pattern and status class.",
+			"method", "route", "status")),
+		httpDuration: register(reg, newHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by method, route pattern and status class.",
+			httpDurationBuckets, "method", "route", "status")),
+		httpResponseBytes: register(reg, newCounterVec("http_response_size_bytes_total",
+			"Total HTTP response body bytes by method, route pattern and status class.",
+			"method", "route", "status")),
+		repoDuration: register(reg, newHistogramVec("user_repository_operation_duration_seconds",
+			"UserRepository call latency by operation and outcome.",
+			repoDurationBuckets, "operation", "outcome")),
+	}
+}
+
+// observeRequest records one completed HTTP request. Requests that matched no
+// route are grouped together to keep label cardinality bounded.
+func (m *appMetrics) observeRequest(method, route string, status int, size int64, d time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	class := statusClass(status)
+	m.httpRequests.inc(method, route, class)
+	m.httpDuration.observe(d.Seconds(), method, route, class)
+	m.httpResponseBytes.add(float64(size), method, route, class)
+}
+
+// metricsHandler serves all metrics in the Prometheus text format.
+// GET /metrics
+// curl http://localhost:8080/metrics
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	app.metrics.registry.writeTo(&buf)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		app.logger.ErrorContext(r.Context(), "failed to write metrics", "error", err)
+	}
+}
+
+// =============================================================================
+// REPOSITORY INSTRUMENTATION
+// =============================================================================
+
+// userCounter is implemented by repositories that can report their size cheaply.
+type userCounter interface {
+	Count(ctx context.Context) (int, error)
+}
+
+// Count returns the number of users in the store, excluding soft-deleted ones.
+func (r *InMemoryUserRepository) Count(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, user := range r.users {
+		if user.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// instrumentedUserRepository is a UserRepository decorator that records the
+// latency and outcome of every call.
+type instrumentedUserRepository struct {
+	repo    UserRepository
+	metrics *appMetrics
+}
+
+// NewInstrumentedUserRepository wraps repo so its calls are measured, and
+// registers a gauge for the number of users if repo can count them.
+func NewInstrumentedUserRepository(repo UserRepository, m *appMetrics) UserRepository {
+	if counter, ok := repositoryAs[userCounter](repo); ok {
+		register(m.registry, newGaugeFunc("users", "Current number of users in the repository.",
+			func() (float64, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				n, err := counter.Count(ctx)
+				return float64(n), err
+			}))
+	}
+	return &instrumentedUserRepository{repo: repo, metrics: m}
+}
+
+// observe records a call that started at start. It is deferred, so err points
+// at the method's named result and is read only once the call has returned.
+func (r *instrumentedUserRepository) observe(op string, start time.Time, err *error) {
+	outcome := "success"
+	if *err != nil {
+		outcome = "error"
+	}
+	r.metrics.repoDuration.observe(time.Since(start).Seconds(), op, outcome)
+}
+
+func (r *instrumentedUserRepository) Create(ctx context.Context, user User) (u User, err error) {
+	defer r.observe("create", time.Now(), &err)
+	return r.repo.Create(ctx, user)
+}
+
+func (r *instrumentedUserRepository) GetByID(ctx context.Context, id string) (u User, err error) {
+	defer r.observe("get_by_id", time.Now(), &err)
+	return r.repo.GetByID(ctx, id)
+}
+
+func (r *instrumentedUserRepository) GetAll(ctx context.Context) (users []User, err error) {
+	defer r.observe("get_all", time.Now(), &err)
+	return r.repo.GetAll(ctx)
+}
+
+func (r *instrumentedUserRepository) List(ctx context.Context, opts ListOptions) (page UserPage, err error) {
+	defer r.observe("list", time.Now(), &err)
+	return r.repo.List(ctx, opts)
+}
+
+func (r *instrumentedUserRepository) Update(ctx context.Context, id string, user User) (u User, err error) {
+	defer r.observe("update", time.Now(), &err)
+	return r.repo.Update(ctx, id, user)
+}
+
+func (r *instrumentedUserRepository) Delete(ctx context.Context, id string, version int64) (err error) {
+	defer r.observe("delete", time.Now(), &err)
+	return r.repo.Delete(ctx, id, version)
+}
+
+func (r *instrumentedUserRepository) GetDeleted(ctx context.Context, id string) (u User, err error) {
+	defer r.observe("get_deleted", time.Now(), &err)
+	return r.repo.GetDeleted(ctx, id)
+}
+
+func (r *instrumentedUserRepository) Restore(ctx context.Context, id string, version int64) (u User, err error) {
+	defer r.observe("restore", time.Now(), &err)
+	return r.repo.Restore(ctx, id, version)
+}
+
+func (r *instrumentedUserRepository) Purge(ctx context.Context, opts PurgeOptions) (n int, err error) {
+	defer r.observe("purge", time.Now(), &err)
+	return r.repo.Purge(ctx, opts)
+}
+
+// Unwrap returns the wrapped repository.
+func (r *instrumentedUserRepository) Unwrap() UserRepository {
+	return r.repo
+}
+
+// Ping forwards to the wrapped repository, if it can be pinged.
+func (r *instrumentedUserRepository) Ping(ctx context.Context) (err error) {
+	pinger, ok := repositoryAs[Pinger](r.repo)
+	if !ok {
+		return nil
+	}
+	defer r.observe("ping", time.Now(), &err)
+	return pinger.Ping(ctx)
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: middleware.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Composable middleware: panic recovery, CORS with preflight
+// handling, and gzip/deflate response compression negotiated from
+// Accept-Encoding.
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// middleware wraps a handler with cross-cutting behavior.
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in mws. The first middleware is the outermost, so it sees the
+// request first and the response last.
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for _, mw := range slices.Backward(mws) {
+		h = mw(h)
+	}
+	return h
+}
+
+// =============================================================================
+// Panic recovery
+// =============================================================================
+
+// recoverMiddleware turns a panicking handler into a logged stack trace and a
+// 500 problem response, instead of a dropped connection. If the response has
+// already started, it is too late to replace it, so the connection is aborted
+// and the client sees an incomplete response rather than a truncated success.
+// Panics with http.ErrAbortHandler are deliberate aborts and pass through.
+func (app *application) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers set by outer middleware survive a panic; the handler's do not.
+		header := w.Header().Clone()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			app.logger.ErrorContext(r.Context(), "panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(v),
+				"stack", string(debug.Stack()),
+			)
+			if rec.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+
+			clear(w.Header())
+			maps.Copy(w.Header(), header)
+			w.Header().Set("Connection", "close")
+			app.writeError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// =============================================================================
+// CORS
+// =============================================================================
+
+var (
+	// corsAllowedMethods are the methods a cross-origin request may use.
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE"
+	// corsAllowedHeaders are the request headers the API reads.
+	corsAllowedHeaders = strings.Join([]string{
+		"Accept-Language", "Authorization", "Content-Type", "If-Match", "If-None-Match",
+		"Last-Event-ID", "traceparent", apiKeyHeader, idempotencyKeyHeader, requestIDHeader,
+	}, ", ")
+	// corsExposedHeaders are the response headers scripts may read, beyond
+	// the CORS-safelisted ones.
+	corsExposedHeaders = strings.Join([]string{
+		"ETag", "Location", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+		"WWW-Authenticate", "Idempotent-Replayed", "traceparent", requestIDHeader,
+	}, ", ")
+)
+
+// validateCORSOrigin checks an entry of Config.CORSAllowedOrigins: "*", or an
+// origin such as https://app.example.com.
+func validateCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q is not an origin such as https://app.example.com", origin)
+	}
+	return nil
+}
+
+// corsMiddleware lets browsers on the configured origins call the API. It
+// answers preflight requests itself, before authentication, since browsers
+// send them without credentials. With no origins configured it does nothing.
+func (app *application) corsMiddleware(next http.Handler) http.Handler {
+	if len(app.config.CORSAllowedOrigins) == 0 {
+		return next
+	}
+	// Origins compare case-insensitively in their scheme and host.
+	origins := make([]string, len(app.config.CORSAllowedOrigins))
+	for i, origin := range app.config.CORSAllowedOrigins {
+		origins[i] = strings.ToLower(origin)
+	}
+	anyOrigin := slices.Contains(origins, "*")
+	maxAge := strconv.Itoa(int(app.config.CORSMaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && (anyOrigin || slices.Contains(origins, strings.ToLower(origin)))
+		switch {
+		case preflight && !allowed:
+			app.writeError(w, r, http.StatusForbidden, "cross-origin requests from this origin are not allowed")
+			return
+		case !allowed:
+			// Same-origin and non-browser requests need no CORS headers.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// =============================================================================
+// Response compression
+// =============================================================================
+
+// compressMinBytes is the smallest response body worth compressing. Smaller
+// bodies are buffered until they reach it or the handler returns.
+const compressMinBytes = 1024
+
+// compressor is the interface shared by gzip.Writer and zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressors pools writers per Content-Encoding, as allocating one is costly.
+// HTTP's "deflate" is the zlib format, not raw DEFLATE.
+var compressors = map[string]*sync.Pool{
+	"gzip":    {New: func() any { return gzip.NewWriter(nil) }},
+	"deflate": {New: func() any { return zlib.NewWriter(nil) }},
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring the higher quality and then gzip, or returns "" for neither. A
+// coding listed by name takes its own quality rather than that of "*".
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response of the given media type is text
+// that compresses well. Event streams are excluded, as each event must reach
+// the client as soon as it is written.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		mediaType == ndjsonContentType || mediaType == "application/xml"
+}
+
+// compressMiddleware compresses responses for clients that accept gzip or
+// deflate. ETags are left as they are: they identify the user's version, and
+// If-Match needs the strong tag to keep working for compressing clients.
+func (app *application) compressMiddleware(next http.Handler) http.Handler {
+	if !app.config.Compression {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		// Not deferred: after a panic, the stream must not be completed with
+		// a valid trailer.
+		if err := cw.Close(); err != nil {
+			app.logger.WarnContext(r.Context(), "failed to finish compressed response", "error", err)
+		}
+	})
+}
+
+// compressWriter holds back the status and the first compressMinBytes of the
+// body until it knows whether compressing is worthwhile, then commits to
+// writing either compressed or plain.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status      int
+	wroteHeader bool   // the handler has set the status
+	committed   bool   // the status has been sent on
+	buf         []byte // body held back before committing
+	enc         compressor
+}
+
+// WriteHeader records the status. Responses that cannot or should not be
+// compressed are committed to plain right away.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	if status < 200 {
+		// Informational responses, such as 103 Early Hints, go straight out.
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status, cw.wroteHeader = status, true
+
+	h := cw.Header()
+	length, err := strconv.Atoi(h.Get("Content-Length"))
+	tooSmall := err == nil && length < compressMinBytes
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) || tooSmall {
+		cw.commit(false)
+	}
+}
+
+// Write buffers b until the body is large enough to compress.
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// As net/http would, but before deciding whether to compress.
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.committed {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= compressMinBytes {
+		if err := cw.commit(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush commits to compressing, since a flushing handler is streaming, and
+// sends everything written so far to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.committed {
+		if err := cw.commit(true); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// commit sends the status on, with the compression headers if compress is
+// set, followed by any held-back body.
+func (cw *compressWriter) commit(compress bool) error {
+	cw.committed = true
+	if compress {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.enc = compressors[cw.encoding].Get().(compressor)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Close sends a body that stayed too small to compress as it is, or finishes
+// the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		// Nothing was written; net/http sends an empty 200.
+		return nil
+	}
+	if !cw.committed {
+		return cw.commit(false)
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	compressors[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection, for
+// example to extend deadlines.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
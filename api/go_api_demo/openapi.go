@@ -0,0 +1,411 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: openapi.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: An OpenAPI 3.1 document generated from the route table and the
+// `json` and `validate` tags of the request and response types, served at
+// /api/v1/openapi.json alongside an embedded documentation page.
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonSchema is a JSON Schema (2020-12) object, as used by OpenAPI 3.1.
+type jsonSchema = map[string]any
+
+// apiRoute describes one route: how it is served and how it is documented.
+type apiRoute struct {
+	// Pattern is the ServeMux pattern, e.g. "GET /api/v1/users/{id}".
+	Pattern string
+	Handler http.HandlerFunc
+
+	OperationID string
+	Summary     string
+	Tag         string
+	// Public routes are served without authentication.
+	Public bool
+
+	// Params lists query and header parameters. Path parameters are derived
+	// from the pattern.
+	Params []apiParam
+	// Body is a value of the request body type, or nil if there is none.
+	Body any
+	// BodyMediaType defaults to application/json. Merge patch bodies are
+	// documented with every field optional.
+	BodyMediaType string
+
+	// Status is the success status code; it defaults to 200.
+	Status int
+	// Data is a value of the type returned in the envelope's "data" field, or
+	// nil if the envelope has none. List selects the paginated envelope.
+	Data any
+	List bool
+	// ResponseMediaType is set for routes that do not return a JSON envelope.
+	ResponseMediaType string
+}
+
+// apiParam is a documented query or header parameter.
+type apiParam struct {
+	Name        string
+	In          string // "query" or "header"
+	Description string
+	Required    bool
+	// Schema defaults to a plain string.
+	Schema jsonSchema
+}
+
+// pathParamPattern matches the wildcards of a ServeMux pattern.
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// openAPIBuilder accumulates named component schemas while documenting routes.
+type openAPIBuilder struct {
+	schemas map[string]jsonSchema
+}
+
+// buildOpenAPIDocument returns the OpenAPI 3.1 document for routes.
+func buildOpenAPIDocument(routes []apiRoute) jsonSchema {
+	b := &openAPIBuilder{schemas: make(map[string]jsonSchema)}
+	b.schemas["Problem"] = b.structSchema(reflect.TypeFor[problemDetails]())
+
+	paths := make(map[string]jsonSchema)
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route.Pattern, " ")
+		if paths[path] == nil {
+			paths[path] = make(jsonSchema)
+		}
+		paths[path][strings.ToLower(method)] = b.operation(route, path)
+	}
+
+	return jsonSchema{
+		"openapi": "3.1.0",
+		"info": jsonSchema{
+			"title":       "go_api_demo",
+			"version":     "2.0.0",
+			"description": "A demonstration HTTP API for managing users within organizations.",
+		},
+		"paths": paths,
+		"components": jsonSchema{
+			"schemas": b.schemas,
+			"responses": jsonSchema{
+				"Problem": jsonSchema{
+					"description": "An RFC 7807 problem describing why the request failed.",
+					"content": jsonSchema{
+						"application/problem+json": jsonSchema{"schema": jsonSchema{"$ref": "#/components/schemas/Problem"}},
+					},
+				},
+			},
+			"securitySchemes": jsonSchema{
+				"apiKey":     jsonSchema{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+				"bearerAuth": jsonSchema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []jsonSchema{{"apiKey": []string{}}, {"bearerAuth": []string{}}},
+	}
+}
+
+// operation documents a single route.
+func (b *openAPIBuilder) operation(route apiRoute, path string) jsonSchema {
+	op := jsonSchema{
+		"operationId": route.OperationID,
+		"summary":     route.Summary,
+		"tags":        []string{route.Tag},
+	}
+	if route.Public {
+		op["security"] = []jsonSchema{}
+	}
+
+	var params []jsonSchema
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, jsonSchema{
+			"name": m[1], "in": "path", "required": true, "schema": jsonSchema{"type": "string"},
+		})
+	}
+	for _, p := range route.Params {
+		schema := p.Schema
+		if schema == nil {
+			schema = jsonSchema{"type": "string"}
+		}
+		param := jsonSchema{"name": p.Name, "in": p.In, "required": p.Required, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Body != nil {
+		mediaType := route.BodyMediaType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		schema := b.schemaOf(reflect.TypeOf(route.Body))
+		if mediaType == mergePatchContentType {
+			// Every field of a merge patch is optional.
+			schema = b.structSchema(reflect.TypeOf(route.Body))
+			delete(schema, "required")
+		}
+		content := jsonSchema{mediaType: jsonSchema{"schema": schema}}
+		if mediaType == ndjsonContentType {
+			// Bulk uploads are a stream of bodies, one per line, or the same
+			// fields as CSV columns.
+			content[csvContentType] = jsonSchema{"schema": jsonSchema{"type": "string"}}
+		}
+		op["requestBody"] = jsonSchema{
+			"required": true,
+			"content":  content,
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op["responses"] = jsonSchema{
+		strconv.Itoa(status): jsonSchema{
+			"description": http.StatusText(status),
+			"content":     b.responseContent(route),
+		},
+		"default": jsonSchema{"$ref": "#/components/responses/Problem"},
+	}
+	return op
+}
+
+// responseContent documents the success body of route.
+func (b *openAPIBuilder) responseContent(route apiRoute) jsonSchema {
+	switch route.ResponseMediaType {
+	case "":
+	case "application/json":
+		return jsonSchema{route.ResponseMediaType: jsonSchema{"schema": jsonSchema{"type": "object"}}}
+	default:
+		return jsonSchema{route.ResponseMediaType: jsonSchema{"schema": jsonSchema{"type": "string"}}}
+	}
+
+	var envelope jsonSchema
+	if route.List {
+		envelope = b.structSchema(reflect.TypeFor[listResponse]())
+	} else {
+		envelope = b.structSchema(reflect.TypeFor[jsonResponse]())
+	}
+	props := envelope["properties"].(jsonSchema)
+	switch {
+	case route.Data == nil:
+		delete(props, "data")
+	case route.List:
+		props["data"] = jsonSchema{"type": "array", "items": b.schemaOf(reflect.TypeOf(route.Data))}
+	default:
+		props["data"] = b.schemaOf(reflect.TypeOf(route.Data))
+	}
+	return jsonSchema{"application/json": jsonSchema{"schema": envelope}}
+}
+
+// schemaOf returns the schema for t. Named struct types are added to the
+// components and referenced, so each is documented once.
+func (b *openAPIBuilder) schemaOf(t reflect.Type) jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := componentName(t)
+		if _, ok := b.schemas[name]; !ok {
+			b.schemas[name] = nil // reserve the name so recursive types terminate
+			b.schemas[name] = b.structSchema(t)
+		}
+		return jsonSchema{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.structSchema(t)
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int64:
+		return jsonSchema{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return jsonSchema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return jsonSchema{"type": "array", "items": b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+	default:
+		// Interfaces and anything else may hold any JSON value.
+		return jsonSchema{}
+	}
+}
+
+// structSchema returns an inline object schema for the struct type t, named
+// and constrained by the fields' `json` and `validate` tags.
+func (b *openAPIBuilder) structSchema(t reflect.Type) jsonSchema {
+	props := make(jsonSchema)
+	var required []string
+	b.addFields(t, props, &required)
+
+	schema := jsonSchema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the JSON-visible fields of t to props, flattening embedded
+// structs the way encoding/json does.
+func (b *openAPIBuilder) addFields(t reflect.Type, props jsonSchema, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(ft, props, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.schemaOf(field.Type)
+		if applyValidateTag(schema, field.Type, field.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+		props[name] = schema
+	}
+}
+
+// applyValidateTag translates the validator rules in tag into JSON Schema
+// keywords on schema, and reports whether the field is required. Rules with
+// no schema equivalent are ignored, so the document may be looser than the
+// server, but never stricter.
+func applyValidateTag(schema jsonSchema, t reflect.Type, tag string) (required bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if _, isRef := schema["$ref"]; isRef {
+		// Siblings of $ref are allowed in 3.1, but only presence matters here.
+		return strings.Contains(","+tag+",", ",required,")
+	}
+
+	var minKey, maxKey string
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		minKey, maxKey = "minItems", "maxItems"
+		if t.Kind() == reflect.Map {
+			minKey, maxKey = "minProperties", "maxProperties"
+		}
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			// Later rules apply to elements, which are not described here.
+			return required
+		case "required":
+			required = true
+		case "min", "gte":
+			setNumber(schema, minKey, param)
+		case "max", "lte":
+			setNumber(schema, maxKey, param)
+		case "len":
+			setNumber(schema, minKey, param)
+			setNumber(schema, maxKey, param)
+		case "gt":
+			if minKey == "minimum" {
+				setNumber(schema, "exclusiveMinimum", param)
+			}
+		case "lt":
+			if maxKey == "maximum" {
+				setNumber(schema, "exclusiveMaximum", param)
+			}
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri", "http_url":
+			schema["format"] = "uri"
+		case "uuid", "uuid4", "uuid7":
+			schema["format"] = "uuid"
+		case "oneof":
+			var values []any
+			for v := range strings.FieldsSeq(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && t.Kind() != reflect.String {
+					values = append(values, n)
+				} else {
+					values = append(values, v)
+				}
+			}
+			schema["enum"] = values
+		}
+	}
+	return required
+}
+
+// setNumber sets schema[key] to the numeric value of param, if it is one.
+func setNumber(schema jsonSchema, key, param string) {
+	if n, err := strconv.ParseFloat(param, 64); err == nil {
+		schema[key] = n
+	}
+}
+
+// componentName returns the component name for a named type, capitalized so
+// unexported types such as userInput read naturally in generated SDKs.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// openAPIHandler serves the OpenAPI document for every registered route.
+// GET /api/v1/openapi.json
+// curl http://localhost:8080/api/v1/openapi.json
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, buildOpenAPIDocument(app.apiRoutes()))
+}
+
+// docsPage renders openapi.json in the browser without any external assets.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// docsHandler serves the embedded documentation page.
+// GET /api/v1/docs
+func (app *application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(docsPage); err != nil {
+		app.logger.ErrorContext(r.Context(), "failed to write docs page", "error", err)
+	}
+}
@@ -0,0 +1,737 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: orgs.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Organizations, memberships and role-based access control.
+// Every user belongs to an organization, and handlers only ever see users
+// through an org-scoped view of the UserRepository.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// DOMAIN MODELS & PERMISSIONS
+// =============================================================================
+
+// Organization is a tenant that owns a set of users.
+type Organization struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Name      string    `json:"name" validate:"required,min=2,max=100"`
+}
+
+// orgInput is the request body for creating or renaming an organization.
+type orgInput struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+// Role is a member's role within an organization.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// Membership grants a principal a role in an organization. A principal belongs
+// to at most one organization.
+type Membership struct {
+	OrgID       string    `json:"orgId"`
+	PrincipalID string    `json:"principalId"`
+	Role        Role      `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// memberInput is the request body for adding a member or changing its role.
+type memberInput struct {
+	Role Role `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// Permission is an action guarded by RBAC.
+type Permission string
+
+const (
+	permUsersRead    Permission = "users:read"
+	permUsersWrite   Permission = "users:write"
+	permOrgRead      Permission = "org:read"
+	permOrgWrite     Permission = "org:write"
+	permMembersRead  Permission = "members:read"
+	permMembersWrite Permission = "members:write"
+	// permUsersReadDeleted allows listing soft-deleted users.
+	permUsersReadDeleted Permission = "users:read_deleted"
+	// permUsersPurge allows permanently removing soft-deleted users.
+	permUsersPurge Permission = "users:purge"
+	// permWebhooksManage allows managing webhook subscriptions and their dead letters.
+	permWebhooksManage Permission = "webhooks:manage"
+)
+
+// rolePermissions lists what each role may do. Roles are cumulative: admins
+// can do everything members can, and owners everything admins can.
+var rolePermissions = map[Role][]Permission{
+	RoleMember: {permUsersRead, permOrgRead},
+	RoleAdmin:  {permUsersRead, permOrgRead, permUsersWrite, permMembersRead, permUsersReadDeleted, permWebhooksManage},
+	RoleOwner:  {permUsersRead, permOrgRead, permUsersWrite, permMembersRead, permUsersReadDeleted, permWebhooksManage, permOrgWrite, permMembersWrite, permUsersPurge},
+}
+
+// Can reports whether the role grants perm.
+func (r Role) Can(perm Permission) bool {
+	return slices.Contains(rolePermissions[r], perm)
+}
+
+// =============================================================================
+// REPOSITORIES
+// =============================================================================
+
+// Errors returned by OrganizationRepository implementations.
+var (
+	// ErrOrgNotFound is returned when the organization does not exist.
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrMemberNotFound is returned when the principal has no membership.
+	ErrMemberNotFound = errors.New("membership not found")
+	// ErrAlreadyMember is returned when a principal already belongs to a
+	// different organization. It wraps ErrConflict.
+	ErrAlreadyMember = fmt.Errorf("%w: principal already belongs to an organization", ErrConflict)
+	// ErrLastOwner is returned when a change would leave an organization
+	// without an owner. It wraps ErrConflict.
+	ErrLastOwner = fmt.Errorf("%w: organization must keep at least one owner", ErrConflict)
+)
+
+// OrganizationRepository stores organizations and their memberships.
+type OrganizationRepository interface {
+	// CreateOrg stores org and makes ownerID its first owner, atomically.
+	CreateOrg(ctx context.Context, org Organization, ownerID string) (Organization, error)
+	GetOrg(ctx context.Context, id string) (Organization, error)
+	UpdateOrg(ctx context.Context, org Organization) (Organization, error)
+	// DeleteOrg removes the organization and all of its memberships.
+	DeleteOrg(ctx context.Context, id string) error
+
+	// GetMembership returns the membership of a principal in any organization.
+	GetMembership(ctx context.Context, principalID string) (Membership, error)
+	ListMembers(ctx context.Context, orgID string) ([]Membership, error)
+	// SetMember adds a principal to an organization or changes its role.
+	SetMember(ctx context.Context, m Membership) (Membership, error)
+	RemoveMember(ctx context.Context, orgID, principalID string) error
+}
+
+// InMemoryOrganizationRepository is a thread-safe, in-memory OrganizationRepository.
+type InMemoryOrganizationRepository struct {
+	mu      sync.RWMutex
+	orgs    map[string]Organization
+	members map[string]Membership // principal ID -> membership
+}
+
+// NewInMemoryOrganizationRepository creates an empty organization store.
+func NewInMemoryOrganizationRepository() *InMemoryOrganizationRepository {
+	return &InMemoryOrganizationRepository{
+		orgs:    make(map[string]Organization),
+		members: make(map[string]Membership),
+	}
+}
+
+// CreateOrg stores a new organization with ownerID as its owner.
+func (r *InMemoryOrganizationRepository) CreateOrg(ctx context.Context, org Organization, ownerID string) (Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Organization{}, err
+	}
+
+	if _, exists := r.orgs[org.ID]; exists {
+		return Organization{}, fmt.Errorf("%w: organization with ID %s already exists", ErrConflict, org.ID)
+	}
+	if _, exists := r.members[ownerID]; exists {
+		return Organization{}, ErrAlreadyMember
+	}
+
+	r.orgs[org.ID] = org
+	r.members[ownerID] = Membership{OrgID: org.ID, PrincipalID: ownerID, Role: RoleOwner, CreatedAt: org.CreatedAt}
+	return org, nil
+}
+
+// GetOrg retrieves an organization by ID.
+func (r *InMemoryOrganizationRepository) GetOrg(ctx context.Context, id string) (Organization, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return Organization{}, err
+	}
+
+	org, exists := r.orgs[id]
+	if !exists {
+		return Organization{}, ErrOrgNotFound
+	}
+	return org, nil
+}
+
+// UpdateOrg replaces an existing organization.
+func (r *InMemoryOrganizationRepository) UpdateOrg(ctx context.Context, org Organization) (Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Organization{}, err
+	}
+
+	if _, exists := r.orgs[org.ID]; !exists {
+		return Organization{}, ErrOrgNotFound
+	}
+	r.orgs[org.ID] = org
+	return org, nil
+}
+
+// DeleteOrg removes an organization and its memberships.
+func (r *InMemoryOrganizationRepository) DeleteOrg(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, exists := r.orgs[id]; !exists {
+		return ErrOrgNotFound
+	}
+	delete(r.orgs, id)
+	for principalID, m := range r.members {
+		if m.OrgID == id {
+			delete(r.members, principalID)
+		}
+	}
+	return nil
+}
+
+// GetMembership returns the organization membership of a principal.
+func (r *InMemoryOrganizationRepository) GetMembership(ctx context.Context, principalID string) (Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return Membership{}, err
+	}
+
+	m, exists := r.members[principalID]
+	if !exists {
+		return Membership{}, ErrMemberNotFound
+	}
+	return m, nil
+}
+
+// ListMembers returns the members of an organization, ordered by principal ID.
+func (r *InMemoryOrganizationRepository) ListMembers(ctx context.Context, orgID string) ([]Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, exists := r.orgs[orgID]; !exists {
+		return nil, ErrOrgNotFound
+	}
+	members := make([]Membership, 0)
+	for _, m := range r.members {
+		if m.OrgID == orgID {
+			members = append(members, m)
+		}
+	}
+	slices.SortFunc(members, func(a, b Membership) int {
+		return strings.Compare(a.PrincipalID, b.PrincipalID)
+	})
+	return members, nil
+}
+
+// SetMember adds a principal to an organization or changes its role.
+func (r *InMemoryOrganizationRepository) SetMember(ctx context.Context, m Membership) (Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Membership{}, err
+	}
+
+	if _, exists := r.orgs[m.OrgID]; !exists {
+		return Membership{}, ErrOrgNotFound
+	}
+	existing, exists := r.members[m.PrincipalID]
+	if exists && existing.OrgID != m.OrgID {
+		return Membership{}, ErrAlreadyMember
+	}
+	if exists {
+		if existing.Role == RoleOwner && m.Role != RoleOwner && r.ownerCountLocked(m.OrgID) == 1 {
+			return Membership{}, ErrLastOwner
+		}
+		m.CreatedAt = existing.CreatedAt
+	}
+
+	r.members[m.PrincipalID] = m
+	return m, nil
+}
+
+// RemoveMember removes a principal from an organization.
+func (r *InMemoryOrganizationRepository) RemoveMember(ctx context.Context, orgID, principalID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m, exists := r.members[principalID]
+	if !exists || m.OrgID != orgID {
+		return ErrMemberNotFound
+	}
+	if m.Role == RoleOwner && r.ownerCountLocked(orgID) == 1 {
+		return ErrLastOwner
+	}
+	delete(r.members, principalID)
+	return nil
+}
+
+// ownerCountLocked counts the owners of an organization. The caller must hold the lock.
+func (r *InMemoryOrganizationRepository) ownerCountLocked(orgID string) int {
+	n := 0
+	for _, m := range r.members {
+		if m.OrgID == orgID && m.Role == RoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// orgUserRepository is a view of a UserRepository restricted to one
+// organization. Users outside the organization behave as if they do not
+// exist, and every write is pinned to the organization, so handlers holding
+// this view cannot reach another tenant's data.
+type orgUserRepository struct {
+	repo  UserRepository
+	orgID string
+}
+
+// ScopedUserRepository returns a UserRepository that only sees users in orgID.
+func ScopedUserRepository(repo UserRepository, orgID string) UserRepository {
+	return &orgUserRepository{repo: repo, orgID: orgID}
+}
+
+// Create stores user in the scoped organization.
+func (r *orgUserRepository) Create(ctx context.Context, user User) (User, error) {
+	user.OrgID = r.orgID
+	return r.repo.Create(ctx, user)
+}
+
+// GetByID returns the user if it belongs to the scoped organization.
+func (r *orgUserRepository) GetByID(ctx context.Context, id string) (User, error) {
+	user, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if user.OrgID != r.orgID {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// GetAll returns every user in the scoped organization.
+func (r *orgUserRepository) GetAll(ctx context.Context) ([]User, error) {
+	all, err := r.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(all, func(u User) bool { return u.OrgID != r.orgID }), nil
+}
+
+// List returns a page of users in the scoped organization.
+func (r *orgUserRepository) List(ctx context.Context, opts ListOptions) (UserPage, error) {
+	opts.OrgID = r.orgID
+	return r.repo.List(ctx, opts)
+}
+
+// Update modifies a user in the scoped organization. The write is pinned to
+// the version that was checked, so the user cannot change hands in between.
+func (r *orgUserRepository) Update(ctx context.Context, id string, user User) (User, error) {
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if user.Version == 0 {
+		user.Version = current.Version
+	}
+	user.OrgID = r.orgID
+	return r.repo.Update(ctx, id, user)
+}
+
+// Delete removes a user in the scoped organization, pinned to the checked version.
+func (r *orgUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		version = current.Version
+	}
+	return r.repo.Delete(ctx, id, version)
+}
+
+// GetDeleted returns the soft-deleted user if it belongs to the scoped organization.
+func (r *orgUserRepository) GetDeleted(ctx context.Context, id string) (User, error) {
+	user, err := r.repo.GetDeleted(ctx, id)
+	if errors.Is(err, ErrNotDeleted) {
+		// Only report that the user is live if it is visible in this org.
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return User{}, err
+		}
+		return User{}, ErrNotDeleted
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if user.OrgID != r.orgID {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// Restore undeletes a user in the scoped organization, pinned to the checked version.
+func (r *orgUserRepository) Restore(ctx context.Context, id string, version int64) (User, error) {
+	current, err := r.GetDeleted(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if version == 0 {
+		version = current.Version
+	}
+	return r.repo.Restore(ctx, id, version)
+}
+
+// Purge permanently removes soft-deleted users in the scoped organization.
+func (r *orgUserRepository) Purge(ctx context.Context, opts PurgeOptions) (int, error) {
+	opts.OrgID = r.orgID
+	return r.repo.Purge(ctx, opts)
+}
+
+// =============================================================================
+// AUTHORIZATION HELPERS
+// =============================================================================
+
+// authorize resolves the caller's membership and checks that its role grants
+// perm. On failure it writes the response and returns false.
+func (app *application) authorize(w http.ResponseWriter, r *http.Request, perm Permission) (Membership, bool) {
+	principal, ok := principalFromContext(r.Context())
+	if !ok {
+		app.writeError(w, r, http.StatusUnauthorized, "authentication required")
+		return Membership{}, false
+	}
+
+	m, err := app.orgs.GetMembership(r.Context(), principal.ID)
+	switch {
+	case errors.Is(err, ErrMemberNotFound):
+		app.writeError(w, r, http.StatusForbidden, "you are not a member of any organization")
+		return Membership{}, false
+	case err != nil:
+		app.writeOrgError(w, r, err)
+		return Membership{}, false
+	case !m.Role.Can(perm):
+		app.writeError(w, r, http.StatusForbidden, fmt.Sprintf("role %q does not grant %q", m.Role, perm))
+		return Membership{}, false
+	}
+	return m, true
+}
+
+// authorizeOrg is authorize for routes with an {orgID} path parameter. Other
+// organizations are reported as not found so their existence is not revealed.
+func (app *application) authorizeOrg(w http.ResponseWriter, r *http.Request, perm Permission) (Membership, bool) {
+	m, ok := app.authorize(w, r, perm)
+	if !ok {
+		return Membership{}, false
+	}
+	orgID, ok := app.pathID(w, r, "orgID")
+	if !ok {
+		return Membership{}, false
+	}
+	if m.OrgID != orgID {
+		app.writeError(w, r, http.StatusNotFound, "organization not found")
+		return Membership{}, false
+	}
+	return m, true
+}
+
+// scopedUsers checks perm and returns the caller's org-scoped user repository.
+func (app *application) scopedUsers(w http.ResponseWriter, r *http.Request, perm Permission) (UserRepository, bool) {
+	m, ok := app.authorize(w, r, perm)
+	if !ok {
+		return nil, false
+	}
+	return ScopedUserRepository(app.users, m.OrgID), true
+}
+
+// writeOrgError maps an OrganizationRepository error to an HTTP response.
+func (app *application) writeOrgError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrOrgNotFound):
+		app.writeError(w, r, http.StatusNotFound, "organization not found")
+	case errors.Is(err, ErrMemberNotFound):
+		app.writeError(w, r, http.StatusNotFound, "member not found")
+	case errors.Is(err, ErrAlreadyMember):
+		app.writeError(w, r, http.StatusConflict, "the principal already belongs to an organization")
+	case errors.Is(err, ErrLastOwner):
+		app.writeError(w, r, http.StatusConflict, "an organization must keep at least one owner")
+	default:
+		app.writeRepositoryError(w, r, err)
+	}
+}
+
+// =============================================================================
+// HTTP HANDLERS
+// =============================================================================
+
+// createOrgHandler creates an organization owned by the caller.
+// POST /api/v1/orgs
+//
+//	curl -X POST -H "Content-Type: application/json" \
+//	 -d '{"name": "Acme"}' \
+//	 http://localhost:8080/api/v1/orgs
+func (app *application) createOrgHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromContext(r.Context())
+	if !ok {
+		app.writeError(w, r, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var input orgInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.writeBadRequest(w, r, err)
+		return
+	}
+
+	org := Organization{
+		ID:        app.ids.NewID(),
+		CreatedAt: time.Now(),
+		Name:      input.Name,
+	}
+	created, err := app.orgs.CreateOrg(r.Context(), org, principal.ID)
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusCreated, jsonResponse{
+		Status:  "success",
+		Message: "Organization created successfully",
+		Data:    created,
+	})
+}
+
+// listOrgsHandler lists the organizations the caller belongs to.
+// GET /api/v1/orgs
+// curl http://localhost:8080/api/v1/orgs
+func (app *application) listOrgsHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromContext(r.Context())
+	if !ok {
+		app.writeError(w, r, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	orgs := make([]Organization, 0, 1)
+	m, err := app.orgs.GetMembership(r.Context(), principal.ID)
+	if err != nil && !errors.Is(err, ErrMemberNotFound) {
+		app.writeOrgError(w, r, err)
+		return
+	}
+	if err == nil {
+		org, err := app.orgs.GetOrg(r.Context(), m.OrgID)
+		if err != nil {
+			app.writeOrgError(w, r, err)
+			return
+		}
+		orgs = append(orgs, org)
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status: "success",
+		Data:   orgs,
+	})
+}
+
+// getOrgHandler retrieves the caller's organization.
+// GET /api/v1/orgs/{orgID}
+// curl http://localhost:8080/api/v1/orgs/{orgID}
+func (app *application) getOrgHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permOrgRead)
+	if !ok {
+		return
+	}
+
+	org, err := app.orgs.GetOrg(r.Context(), m.OrgID)
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status: "success",
+		Data:   org,
+	})
+}
+
+// updateOrgHandler renames the caller's organization. Owners only.
+// PUT /api/v1/orgs/{orgID}
+//
+//	curl -X PUT -H "Content-Type: application/json" \
+//	 -d '{"name": "Acme Corp"}' \
+//	 http://localhost:8080/api/v1/orgs/{orgID}
+func (app *application) updateOrgHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permOrgWrite)
+	if !ok {
+		return
+	}
+
+	var input orgInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.writeBadRequest(w, r, err)
+		return
+	}
+
+	org, err := app.orgs.GetOrg(r.Context(), m.OrgID)
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+	org.Name = input.Name
+
+	updated, err := app.orgs.UpdateOrg(r.Context(), org)
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "Organization updated successfully",
+		Data:    updated,
+	})
+}
+
+// deleteOrgHandler deletes the caller's organization. Owners only, and only
+// once the organization has no users left.
+// DELETE /api/v1/orgs/{orgID}
+// curl -X DELETE http://localhost:8080/api/v1/orgs/{orgID}
+func (app *application) deleteOrgHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permOrgWrite)
+	if !ok {
+		return
+	}
+
+	// Soft-deleted users still belong to the organization until purged.
+	page, err := app.users.List(r.Context(), ListOptions{OrgID: m.OrgID, Limit: 1, IncludeDeleted: true})
+	if err != nil {
+		app.writeRepositoryError(w, r, err)
+		return
+	}
+	if len(page.Users) > 0 {
+		app.writeError(w, r, http.StatusConflict, "the organization still has users; delete and purge them first")
+		return
+	}
+
+	if err := app.orgs.DeleteOrg(r.Context(), m.OrgID); err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "Organization deleted successfully",
+	})
+}
+
+// listMembersHandler lists the members of the caller's organization.
+// GET /api/v1/orgs/{orgID}/members
+// curl http://localhost:8080/api/v1/orgs/{orgID}/members
+func (app *application) listMembersHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permMembersRead)
+	if !ok {
+		return
+	}
+
+	members, err := app.orgs.ListMembers(r.Context(), m.OrgID)
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status: "success",
+		Data:   members,
+	})
+}
+
+// putMemberHandler adds a principal to the caller's organization or changes
+// its role. Owners only.
+// PUT /api/v1/orgs/{orgID}/members/{principalID}
+//
+//	curl -X PUT -H "Content-Type: application/json" \
+//	 -d '{"role": "admin"}' \
+//	 http://localhost:8080/api/v1/orgs/{orgID}/members/{principalID}
+func (app *application) putMemberHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permMembersWrite)
+	if !ok {
+		return
+	}
+
+	var input memberInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.writeBadRequest(w, r, err)
+		return
+	}
+
+	member, err := app.orgs.SetMember(r.Context(), Membership{
+		OrgID:       m.OrgID,
+		PrincipalID: r.PathValue("principalID"),
+		Role:        input.Role,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "Membership saved successfully",
+		Data:    member,
+	})
+}
+
+// deleteMemberHandler removes a principal from the caller's organization.
+// Owners only.
+// DELETE /api/v1/orgs/{orgID}/members/{principalID}
+// curl -X DELETE http://localhost:8080/api/v1/orgs/{orgID}/members/{principalID}
+func (app *application) deleteMemberHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorizeOrg(w, r, permMembersWrite)
+	if !ok {
+		return
+	}
+
+	if err := app.orgs.RemoveMember(r.Context(), m.OrgID, r.PathValue("principalID")); err != nil {
+		app.writeOrgError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "Member removed successfully",
+	})
+}
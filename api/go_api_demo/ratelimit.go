@@ -0,0 +1,212 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: ratelimit.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Per-client token-bucket rate limiting. Each client gets its own
+// bucket per route group, keyed by authenticated principal or client IP, and
+// idle buckets are evicted in the background.
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitRule assigns requests to a named budget. A rule matches when the
+// method is in Methods (or Methods is empty) and the path has PathPrefix.
+type rateLimitRule struct {
+	Group      string
+	Methods    []string
+	PathPrefix string
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the bucket capacity, i.e. the most requests allowed at once.
+	Burst int
+}
+
+// defaultRateLimitRules are evaluated in order; the first match wins. Creating
+// users has its own, tighter budget so it cannot starve everything else.
+var defaultRateLimitRules = []rateLimitRule{
+	{Group: "users-create", Methods: []string{http.MethodPost}, PathPrefix: "/api/v1/users", Rate: 0.5, Burst: 10},
+	{Group: "write", Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}, PathPrefix: "/", Rate: 5, Burst: 20},
+	{Group: "read", PathPrefix: "/", Rate: 20, Burst: 100},
+}
+
+// bucketIdleTTL is how long a bucket may go unused before it is evicted. An
+// evicted bucket is recreated full, so this must be long enough for any
+// bucket to refill completely.
+const bucketIdleTTL = 10 * time.Minute
+
+// tokenBucket is the state of one client's budget in one route group.
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter holds every client's token buckets.
+type rateLimiter struct {
+	rules []rateLimitRule
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter creates a limiter that applies rules in order.
+func newRateLimiter(rules []rateLimitRule) *rateLimiter {
+	return &rateLimiter{
+		rules:   rules,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// rule returns the first rule matching r, or false if none does.
+func (l *rateLimiter) rule(r *http.Request) (rateLimitRule, bool) {
+	for _, rule := range l.rules {
+		if !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+			continue
+		}
+		if len(rule.Methods) > 0 && !containsFold(rule.Methods, r.Method) {
+			continue
+		}
+		return rule, true
+	}
+	return rateLimitRule{}, false
+}
+
+// rateLimitDecision is the outcome of taking a token from a bucket.
+type rateLimitDecision struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration // until one token is available
+	reset      time.Duration // until the bucket is full again
+}
+
+// take removes one token from the bucket for key under rule.
+func (l *rateLimiter) take(key string, rule rateLimitRule) rateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rule.Burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	b.lastSeen = now
+
+	d := rateLimitDecision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = secondsToDuration((1 - b.tokens) / rule.Rate)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = secondsToDuration((float64(rule.Burst) - b.tokens) / rule.Rate)
+	return d
+}
+
+// evictIdle removes buckets that have not been used for bucketIdleTTL.
+func (l *rateLimiter) evictIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := l.now().Add(-bucketIdleTTL)
+	for key, b := range l.buckets {
+		if b.lastSeen.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// run evicts idle buckets periodically until ctx is cancelled.
+func (l *rateLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(bucketIdleTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.evictIdle()
+		}
+	}
+}
+
+// rateLimitMiddleware enforces the limiter's budgets. Authenticated requests
+// are keyed by principal and anonymous ones by client IP. Every limited
+// response carries RateLimit-* headers; rejected requests get a 429 with
+// Retry-After.
+func (app *application) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := app.limiter.rule(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ip:" + clientIP(r)
+		if p, ok := principalFromContext(r.Context()); ok {
+			key = "principal:" + p.ID
+		}
+		d := app.limiter.take(rule.Group+"|"+key, rule)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(rule.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+		if !d.allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+			app.writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded; retry later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP address of the directly connected client. Forwarding
+// headers are deliberately ignored, as they are trivially spoofed unless a
+// trusted proxy sets them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// secondsToDuration converts fractional seconds to a Duration.
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds rounds d up to whole seconds, as used by Retry-After.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: sse.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Server-Sent Events stream of user changes. A broker keeps the
+// most recent events in a ring buffer so clients can resume with
+// Last-Event-ID, and fans new events out to connected streams.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// eventBufferSize is how many recent events can be replayed on resumption.
+	eventBufferSize = 1024
+	// subscriberBufferSize is how far a stream may fall behind before it is
+	// disconnected. The client can then resume from the ring buffer.
+	subscriberBufferSize = 64
+	// sseHeartbeatInterval is how often an idle stream sends a comment, so
+	// proxies do not time it out and dead clients are noticed.
+	sseHeartbeatInterval = 15 * time.Second
+)
+
+// streamedEvent is a UserEvent with its position in the stream, which is sent
+// as the SSE event ID.
+type streamedEvent struct {
+	Seq   uint64
+	Event UserEvent
+}
+
+// eventSubscriber is one connected stream.
+type eventSubscriber struct {
+	orgID string
+	ch    chan streamedEvent
+}
+
+// eventBroker is a UserEventSink that fans events out to SSE streams and
+// remembers the last eventBufferSize of them for resumption.
+type eventBroker struct {
+	mu     sync.Mutex
+	seq    uint64 // sequence number of the latest event; the first is 1
+	ring   [eventBufferSize]streamedEvent
+	subs   map[*eventSubscriber]struct{}
+	closed bool
+}
+
+// newEventBroker creates an empty broker.
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[*eventSubscriber]struct{})}
+}
+
+// Publish numbers event, stores it and sends it to every stream in its
+// organization. A stream that has fallen too far behind is disconnected
+// instead of blocking the writer.
+func (b *eventBroker) Publish(event UserEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e := streamedEvent{Seq: b.seq, Event: event}
+	b.ring[b.seq%eventBufferSize] = e
+
+	for sub := range b.subs {
+		if sub.orgID != event.OrgID {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe registers a stream for orgID. When resuming, it also returns the
+// buffered events after lastSeq, which are older than anything sent on the
+// subscriber's channel, so nothing is missed or repeated. It returns nil once
+// the broker is closed.
+func (b *eventBroker) Subscribe(orgID string, resume bool, lastSeq uint64) ([]streamedEvent, *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil
+	}
+
+	var backlog []streamedEvent
+	if resume && lastSeq < b.seq {
+		// Events before the start of the ring have been overwritten.
+		first := max(lastSeq+1, b.seq-min(b.seq, eventBufferSize)+1)
+		for seq := first; seq <= b.seq; seq++ {
+			if e := b.ring[seq%eventBufferSize]; e.Event.OrgID == orgID {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	sub := &eventSubscriber{orgID: orgID, ch: make(chan streamedEvent, subscriberBufferSize)}
+	b.subs[sub] = struct{}{}
+	return backlog, sub
+}
+
+// Unsubscribe removes a stream. It is safe to call more than once.
+func (b *eventBroker) Unsubscribe(sub *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Close disconnects every stream and refuses new ones. It is registered with
+// http.Server.RegisterOnShutdown, as Shutdown does not interrupt active
+// handlers and would otherwise wait for the streams until it times out.
+func (b *eventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// userEventsHandler streams changes to users in the caller's organization as
+// Server-Sent Events. A client that reconnects with Last-Event-ID receives
+// the events it missed, as long as they are still in the ring buffer.
+// GET /api/v1/users/events
+// curl -N http://localhost:8080/api/v1/users/events
+func (app *application) userEventsHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permUsersRead)
+	if !ok {
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	var lastSeq uint64
+	if lastEventID != "" {
+		seq, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			app.writeError(w, r, http.StatusBadRequest, "Last-Event-ID must be an event ID from this stream")
+			return
+		}
+		lastSeq = seq
+	}
+
+	// The server's WriteTimeout would cut the stream off, so lift it for
+	// this response only.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.logger.ErrorContext(r.Context(), "failed to clear write deadline for event stream", "error", err)
+		app.writeError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+		return
+	}
+
+	backlog, sub := app.events.Subscribe(m.OrgID, lastEventID != "", lastSeq)
+	if sub == nil {
+		app.writeError(w, r, http.StatusServiceUnavailable, "the server is shutting down")
+		return
+	}
+	defer app.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the client how long to wait before reconnecting, then catch it up.
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, e := range backlog {
+		if err := writeSSE(w, e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.ch:
+			if !ok {
+				// Too slow, or the server is shutting down.
+				return
+			}
+			err = writeSSE(w, e)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes e as a single SSE message.
+func writeSSE(w http.ResponseWriter, e streamedEvent) error {
+	data, err := json.Marshal(e.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Event.Type, data)
+	return err
+}
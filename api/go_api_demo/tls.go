@@ -0,0 +1,297 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: tls.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Native TLS serving. Certificates are loaded from files and
+// reloaded when they change, or generated self-signed for development, and a
+// second listener can redirect plain HTTP to HTTPS.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often, at most, the certificate files are checked
+// for changes. Checks happen during handshakes, so an idle server does no work.
+const certCheckInterval = 5 * time.Second
+
+// tlsVersions maps the accepted values of Config.TLSMinVersion.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsEnabled reports whether c serves HTTPS.
+func (c Config) tlsEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSSelfSigned
+}
+
+// validateTLS checks the TLS settings of c.
+func (c Config) validateTLS() []error {
+	var errs []error
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	if c.TLSSelfSigned && c.TLSCertFile != "" {
+		errs = append(errs, errors.New("tls_self_signed cannot be combined with tls_cert_file"))
+	}
+	if _, ok := tlsVersions[c.TLSMinVersion]; !ok {
+		errs = append(errs, errors.New("tls_min_version must be 1.2 or 1.3"))
+	}
+	if _, err := parseCipherSuites(c.TLSCipherSuites); err != nil {
+		errs = append(errs, fmt.Errorf("tls_cipher_suites: %w", err))
+	} else if c.TLSCipherSuites != "" && c.TLSMinVersion == "1.3" {
+		errs = append(errs, errors.New("tls_cipher_suites has no effect with tls_min_version 1.3"))
+	}
+	if c.HTTPRedirectPort != "" {
+		if !c.tlsEnabled() {
+			errs = append(errs, errors.New("http_redirect_port requires TLS to be enabled"))
+		}
+		if !validPort(c.HTTPRedirectPort) {
+			errs = append(errs, errors.New("http_redirect_port must be a number between 1 and 65535"))
+		}
+		if c.HTTPRedirectPort == c.Port {
+			errs = append(errs, errors.New("http_redirect_port must differ from port"))
+		}
+	}
+	return errs
+}
+
+// parseCipherSuites parses a comma-separated list of TLS 1.2 cipher suite
+// names, as listed by tls.CipherSuites. An empty list selects Go's defaults.
+// TLS 1.3 suites are not configurable in Go, and insecure suites are refused.
+func parseCipherSuites(s string) ([]uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ids []uint16
+	for name := range strings.SplitSeq(s, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(tls.CipherSuites(), func(cs *tls.CipherSuite) bool { return cs.Name == name })
+		if i < 0 {
+			if slices.ContainsFunc(tls.InsecureCipherSuites(), func(cs *tls.CipherSuite) bool { return cs.Name == name }) {
+				return nil, fmt.Errorf("%s is insecure", name)
+			}
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		cs := tls.CipherSuites()[i]
+		if !slices.Contains(cs.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("%s is a TLS 1.3 suite, which cannot be configured", name)
+		}
+		ids = append(ids, cs.ID)
+	}
+	return ids, nil
+}
+
+// newTLSConfig builds the server's TLS configuration from cfg, or returns nil
+// if TLS is disabled.
+func newTLSConfig(cfg Config, logger *slog.Logger) (*tls.Config, error) {
+	if !cfg.tlsEnabled() {
+		return nil, nil
+	}
+
+	suites, err := parseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tlsVersions[cfg.TLSMinVersion],
+		CipherSuites: suites,
+	}
+
+	if cfg.TLSSelfSigned {
+		cert, err := newSelfSignedCert(time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("generate self-signed certificate: %w", err)
+		}
+		fingerprint := sha256.Sum256(cert.Leaf.Raw)
+		logger.Warn("serving a self-signed certificate; do not use in production",
+			"sha256_fingerprint", hex.EncodeToString(fingerprint[:]), "expires", cert.Leaf.NotAfter)
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return tlsConfig, nil
+	}
+
+	reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.GetCertificate = reloader.GetCertificate
+	return tlsConfig, nil
+}
+
+// =============================================================================
+// Certificate reloading
+// =============================================================================
+
+// certReloader serves a certificate from disk, loading it again when either
+// file changes. If the new files cannot be loaded, for example because only
+// one of them has been replaced so far, the previous certificate stays in use
+// and the files are retried at the next check.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certStamp fileStamp
+	keyStamp  fileStamp
+	checked   time.Time
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime int64 // Unix nanoseconds
+	size    int64
+}
+
+func statFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime().UnixNano(), size: fi.Size()}, nil
+}
+
+// newCertReloader loads the certificate in certFile and keyFile. Unlike later
+// reloads, failing to load it here is an error.
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, logger: logger, now: time.Now}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads both files and, if they hold a valid key pair, makes it current.
+// r.mu must be held, or r not yet shared.
+func (r *certReloader) load() error {
+	certStamp, err := statFile(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyStamp, err := statFile(r.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.certStamp, r.keyStamp = &cert, certStamp, keyStamp
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checked) >= certCheckInterval {
+		r.checked = now
+		r.reloadIfChanged()
+	}
+	return r.cert, nil
+}
+
+// reloadIfChanged reloads the certificate if either file has changed since it
+// was loaded. r.mu must be held.
+func (r *certReloader) reloadIfChanged() {
+	certStamp, certErr := statFile(r.certFile)
+	keyStamp, keyErr := statFile(r.keyFile)
+	if err := errors.Join(certErr, keyErr); err != nil {
+		r.logger.Warn("cannot check TLS certificate files; keeping the current certificate", "error", err)
+		return
+	}
+	if certStamp == r.certStamp && keyStamp == r.keyStamp {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		r.logger.Warn("cannot reload TLS certificate; keeping the current certificate", "error", err)
+		return
+	}
+	r.logger.Info("reloaded TLS certificate", "cert_file", r.certFile, "expires", r.cert.Leaf.NotAfter)
+}
+
+// newSelfSignedCert generates an ECDSA certificate for localhost, valid for a
+// week from now.
+func newSelfSignedCert(now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"go_api_demo development"}},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// =============================================================================
+// HTTP to HTTPS redirect
+// =============================================================================
+
+// redirectToHTTPS sends every request to the same host and path on the HTTPS
+// port. 308 keeps the method and body, so API clients that POST over plain
+// HTTP are not silently turned into GETs.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 literal
+		}
+
+		w.Header().Set("Connection", "close")
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: tracing.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Request correlation. Every request gets an X-Request-ID and a
+// W3C Trace Context span, both echoed in the response, and a slog handler
+// adds them to every record logged with the request's context.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	requestIDHeader   = "X-Request-ID"
+	traceparentHeader = "traceparent"
+
+	// maxRequestIDLength bounds client-supplied request IDs, which end up in
+	// every log line of the request.
+	maxRequestIDLength = 128
+)
+
+// traceContext is the part of a W3C traceparent header this server uses.
+// spanID is the span of this server's handling of the request; it is sent
+// back as the parent ID, so callers can link their span to it.
+type traceContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	flags   byte
+}
+
+// String formats t as a version 00 traceparent header.
+func (t traceContext) String() string {
+	return "00-" + hex.EncodeToString(t.traceID[:]) + "-" + hex.EncodeToString(t.spanID[:]) + "-" + hex.EncodeToString([]byte{t.flags})
+}
+
+// parseTraceparent parses a traceparent header, returning the caller's trace
+// ID and flags. As the specification requires, versions above 00 are parsed
+// as far as version 00 defines them, and invalid headers are ignored.
+func parseTraceparent(s string) (traceID [16]byte, flags byte, ok bool) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') {
+		return traceID, 0, false
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return traceID, 0, false
+	}
+	version, traceHex, parentHex, flagsHex := s[0:2], s[3:35], s[36:52], s[53:55]
+	if version == "ff" || (version == "00" && len(s) != 55) {
+		return traceID, 0, false
+	}
+	for _, part := range []string{version, traceHex, parentHex, flagsHex} {
+		if strings.ToLower(part) != part {
+			return traceID, 0, false
+		}
+	}
+
+	var versionByte, flagsByte [1]byte
+	var parentID [8]byte
+	if _, err := hex.Decode(versionByte[:], []byte(version)); err != nil {
+		return traceID, 0, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(traceHex)); err != nil || traceID == [16]byte{} {
+		return [16]byte{}, 0, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parentHex)); err != nil || parentID == [8]byte{} {
+		return [16]byte{}, 0, false
+	}
+	if _, err := hex.Decode(flagsByte[:], []byte(flagsHex)); err != nil {
+		return [16]byte{}, 0, false
+	}
+	return traceID, flagsByte[0], true
+}
+
+// newTraceContext continues the trace in the traceparent header, if it is
+// valid, or starts a new, sampled one. Either way the request gets a new span.
+func newTraceContext(traceparent string) traceContext {
+	var t traceContext
+	if traceID, flags, ok := parseTraceparent(traceparent); ok {
+		t.traceID, t.flags = traceID, flags
+	} else {
+		rand.Read(t.traceID[:])
+		t.flags = 0x01
+	}
+	rand.Read(t.spanID[:])
+	return t
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to
+// reuse: short, and printable ASCII without spaces, so it cannot forge log
+// lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDMiddleware identifies each request. It keeps the caller's
+// X-Request-ID if it is valid and generates one otherwise, continues or starts
+// a trace from traceparent, echoes both in the response, and stores them in
+// the request's metadata for logging.
+func (app *application) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = app.ids.NewID()
+		}
+		trace := newTraceContext(r.Header.Get(traceparentHeader))
+
+		w.Header().Set(requestIDHeader, requestID)
+		w.Header().Set(traceparentHeader, trace.String())
+
+		meta := &requestMeta{requestID: requestID, trace: trace}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestMetaContextKey{}, meta)))
+	})
+}
+
+// contextHandler is a slog.Handler that adds the request ID, trace ID and
+// span ID of the request in a record's context, if any, before passing the
+// record on. Only the *Context logging methods carry a context, so code
+// running on behalf of a request logs through those.
+type contextHandler struct {
+	slog.Handler
+}
+
+// newContextHandler wraps h so records are annotated with request details.
+func newContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{Handler: h}
+}
+
+// Handle implements slog.Handler.
+func (h contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if meta := requestMetaFromContext(ctx); meta != nil {
+		rec.AddAttrs(
+			slog.String("request_id", meta.requestID),
+			slog.String("trace_id", hex.EncodeToString(meta.trace.traceID[:])),
+			slog.String("span_id", hex.EncodeToString(meta.trace.spanID[:])),
+		)
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+// WithAttrs implements slog.Handler.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,636 @@
+// Copyright (c) 2025-present dunamismax. All rights reserved.
+//
+// filename: webhooks.go
+// author:   dunamismax
+// version:  2.0.0
+// date:     10-16-2026
+// github:   <https://github.com/dunamismax>
+// description: Outbound webhooks. Organizations subscribe URLs to user events,
+// and a worker pool delivers each event with an HMAC-SHA256 signature,
+// retrying with exponential backoff before giving up to a dead-letter list.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// DOMAIN MODELS
+// =============================================================================
+
+// Webhook subscribes a URL to user events in one organization.
+type Webhook struct {
+	ID     string          `json:"id"`
+	OrgID  string          `json:"orgId"`
+	URL    string          `json:"url"`
+	Events []UserEventType `json:"events"`
+	// Secret signs every delivery. It is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// webhookInput is the request body for creating a webhook. If Secret is
+// empty, one is generated.
+type webhookInput struct {
+	URL    string          `json:"url" validate:"required,http_url,max=2048"`
+	Events []UserEventType `json:"events" validate:"required,min=1,unique,dive,oneof=user.created user.updated user.deleted user.restored"`
+	Secret string          `json:"secret" validate:"omitempty,min=16,max=256"`
+}
+
+// DeadLetter is a delivery that was given up on, kept so it can be inspected.
+type DeadLetter struct {
+	DeliveryID string    `json:"deliveryId"`
+	WebhookID  string    `json:"webhookId"`
+	OrgID      string    `json:"orgId"`
+	Event      UserEvent `json:"event"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"lastError"`
+	FailedAt   time.Time `json:"failedAt"`
+}
+
+// =============================================================================
+// REPOSITORY
+// =============================================================================
+
+// ErrWebhookNotFound is returned when the webhook does not exist in the
+// requested organization.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookRepository stores webhook subscriptions. Every method is scoped to an
+// organization, so one tenant can never see another's webhooks.
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error)
+	GetWebhook(ctx context.Context, orgID, id string) (Webhook, error)
+	// ListWebhooks returns an organization's webhooks, oldest first.
+	ListWebhooks(ctx context.Context, orgID string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, orgID, id string) error
+}
+
+// InMemoryWebhookRepository is a thread-safe, in-memory WebhookRepository.
+type InMemoryWebhookRepository struct {
+	mu    sync.RWMutex
+	hooks map[string]Webhook
+}
+
+// NewInMemoryWebhookRepository creates an empty webhook store.
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{hooks: make(map[string]Webhook)}
+}
+
+// CreateWebhook stores a new webhook.
+func (r *InMemoryWebhookRepository) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return Webhook{}, err
+	}
+
+	if _, exists := r.hooks[hook.ID]; exists {
+		return Webhook{}, fmt.Errorf("%w: webhook with ID %s already exists", ErrConflict, hook.ID)
+	}
+	hook.Events = slices.Clone(hook.Events)
+	r.hooks[hook.ID] = hook
+	return hook, nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (r *InMemoryWebhookRepository) GetWebhook(ctx context.Context, orgID, id string) (Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return Webhook{}, err
+	}
+
+	hook, exists := r.hooks[id]
+	if !exists || hook.OrgID != orgID {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+// ListWebhooks returns an organization's webhooks, ordered by creation time.
+func (r *InMemoryWebhookRepository) ListWebhooks(ctx context.Context, orgID string) ([]Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hooks := make([]Webhook, 0)
+	for _, hook := range r.hooks {
+		if hook.OrgID == orgID {
+			hooks = append(hooks, hook)
+		}
+	}
+	slices.SortFunc(hooks, func(a, b Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook.
+func (r *InMemoryWebhookRepository) DeleteWebhook(ctx context.Context, orgID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	hook, exists := r.hooks[id]
+	if !exists || hook.OrgID != orgID {
+		return ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	return nil
+}
+
+// =============================================================================
+// DELIVERY
+// =============================================================================
+
+const (
+	// webhookQueueSize bounds the number of deliveries waiting for a worker.
+	webhookQueueSize = 1024
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+	// webhookBaseDelay and webhookMaxDelay bound the backoff between attempts.
+	webhookBaseDelay = time.Second
+	webhookMaxDelay  = 5 * time.Minute
+	// maxDeadLetters bounds the dead-letter list; the oldest are dropped first.
+	maxDeadLetters = 1000
+)
+
+// webhookDelivery is one event on its way to one webhook.
+type webhookDelivery struct {
+	ID        string
+	Webhook   Webhook
+	Event     UserEvent
+	Body      []byte
+	Attempts  int
+	LastError string
+}
+
+// webhookDispatcher is a UserEventSink that delivers events to the webhooks
+// subscribed to them. Deliveries are queued and sent by a pool of workers;
+// failed attempts are retried with exponential backoff and jitter, and after
+// maxAttempts the delivery is moved to the dead-letter list.
+type webhookDispatcher struct {
+	hooks       WebhookRepository
+	ids         IDGenerator
+	logger      *slog.Logger
+	client      *http.Client
+	maxAttempts int
+
+	queue   chan webhookDelivery
+	ctx     context.Context // cancelled to abandon outstanding deliveries
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	// pending counts deliveries that are neither delivered nor dead-lettered.
+	pending sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	deadMu sync.Mutex
+	dead   []DeadLetter
+}
+
+// newWebhookDispatcher creates a dispatcher. Call Start to begin delivering
+// and Close to drain it.
+func newWebhookDispatcher(hooks WebhookRepository, ids IDGenerator, logger *slog.Logger, maxAttempts int) *webhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookDispatcher{
+		hooks:  hooks,
+		ids:    ids,
+		logger: logger,
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			// A redirect is reported as a failure rather than followed, so a
+			// subscriber cannot bounce deliveries to another host.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts: maxAttempts,
+		queue:       make(chan webhookDelivery, webhookQueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start launches n delivery workers.
+func (d *webhookDispatcher) Start(n int) {
+	for range n {
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+			for del := range d.queue {
+				d.attempt(del)
+			}
+		}()
+	}
+}
+
+// Publish queues event for every webhook in its organization subscribed to
+// its type. It never blocks: if the queue is full, the delivery is
+// dead-lettered straight away.
+func (d *webhookDispatcher) Publish(event UserEvent) {
+	hooks, err := d.hooks.ListWebhooks(context.Background(), event.OrgID)
+	if err != nil {
+		d.logger.Error("failed to look up webhooks", "org_id", event.OrgID, "event_id", event.ID, "error", err)
+		return
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		d.logger.Warn("webhook dispatcher is closed; event not delivered", "event_id", event.ID)
+		return
+	}
+
+	var body []byte
+	for _, hook := range hooks {
+		if !slices.Contains(hook.Events, event.Type) {
+			continue
+		}
+		if body == nil {
+			body, _ = json.Marshal(event) // a UserEvent always marshals
+		}
+
+		del := webhookDelivery{ID: d.ids.NewID(), Webhook: hook, Event: event, Body: body}
+		d.pending.Add(1)
+		select {
+		case d.queue <- del:
+		default:
+			del.LastError = "delivery queue is full"
+			d.deadLetter(del)
+		}
+	}
+}
+
+// attempt sends one delivery and settles or reschedules it.
+func (d *webhookDispatcher) attempt(del webhookDelivery) {
+	if d.ctx.Err() != nil {
+		d.abandon(del)
+		return
+	}
+
+	del.Attempts++
+	err := d.send(del)
+	if err == nil {
+		d.logger.Info("webhook delivered", "webhook_id", del.Webhook.ID, "delivery_id", del.ID, "event", del.Event.Type, "attempts", del.Attempts)
+		d.pending.Done()
+		return
+	}
+	del.LastError = err.Error()
+
+	if del.Attempts >= d.maxAttempts {
+		d.deadLetter(del)
+		return
+	}
+
+	delay := webhookBackoff(del.Attempts)
+	d.logger.Warn("webhook delivery failed; will retry",
+		"webhook_id", del.Webhook.ID,
+		"delivery_id", del.ID,
+		"attempts", del.Attempts,
+		"retry_in", delay.String(),
+		"error", err,
+	)
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			select {
+			case d.queue <- del:
+			case <-d.ctx.Done():
+				d.abandon(del)
+			}
+		case <-d.ctx.Done():
+			d.abandon(del)
+		}
+	}()
+}
+
+// send makes a single delivery attempt. Any response other than 2xx is an error.
+func (d *webhookDispatcher) send(del webhookDelivery) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, del.Webhook.URL, bytes.NewReader(del.Body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_api_demo-webhooks/2.0")
+	req.Header.Set("X-Webhook-ID", del.Webhook.ID)
+	req.Header.Set("X-Webhook-Delivery", del.ID)
+	req.Header.Set("X-Webhook-Event", string(del.Event.Type))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Signature", signWebhook(del.Webhook.Secret, timestamp, del.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the X-Signature header value: the hex HMAC-SHA256, keyed
+// with the webhook secret, of the timestamp, a dot and the body. Including the
+// timestamp lets receivers reject replayed deliveries.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the delay before retrying after the given number of
+// attempts: exponential from webhookBaseDelay, capped at webhookMaxDelay,
+// with up to half of it replaced by jitter so retries spread out.
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookMaxDelay
+	if attempts <= 20 {
+		delay = min(webhookBaseDelay<<(attempts-1), webhookMaxDelay)
+	}
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+// abandon dead-letters a delivery that was cut short by Close.
+func (d *webhookDispatcher) abandon(del webhookDelivery) {
+	if del.LastError == "" {
+		del.LastError = "server shut down before the delivery was attempted"
+	}
+	d.deadLetter(del)
+}
+
+// deadLetter gives up on a delivery and records it.
+func (d *webhookDispatcher) deadLetter(del webhookDelivery) {
+	defer d.pending.Done()
+
+	d.logger.Error("webhook delivery failed permanently",
+		"webhook_id", del.Webhook.ID,
+		"delivery_id", del.ID,
+		"attempts", del.Attempts,
+		"error", del.LastError,
+	)
+
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+
+	if len(d.dead) >= maxDeadLetters {
+		d.dead = slices.Delete(d.dead, 0, len(d.dead)-maxDeadLetters+1)
+	}
+	d.dead = append(d.dead, DeadLetter{
+		DeliveryID: del.ID,
+		WebhookID:  del.Webhook.ID,
+		OrgID:      del.Webhook.OrgID,
+		Event:      del.Event,
+		Attempts:   del.Attempts,
+		LastError:  del.LastError,
+		FailedAt:   time.Now(),
+	})
+}
+
+// DeadLetters returns the dead-lettered deliveries for a webhook, newest first.
+func (d *webhookDispatcher) DeadLetters(orgID, webhookID string) []DeadLetter {
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+
+	letters := make([]DeadLetter, 0)
+	for i := len(d.dead) - 1; i >= 0; i-- {
+		if d.dead[i].OrgID == orgID && d.dead[i].WebhookID == webhookID {
+			letters = append(letters, d.dead[i])
+		}
+	}
+	return letters
+}
+
+// Close stops accepting events and waits for outstanding deliveries, including
+// scheduled retries, to settle. If ctx expires first, in-flight attempts are
+// cancelled and everything still outstanding is dead-lettered.
+func (d *webhookDispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		d.cancel()
+		<-drained
+	}
+
+	// Nothing can be queued once every delivery has settled.
+	d.cancel()
+	close(d.queue)
+	d.workers.Wait()
+	return err
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b) // never returns an error
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// =============================================================================
+// HTTP HANDLERS
+// =============================================================================
+
+// writeWebhookError maps a WebhookRepository error to an HTTP response.
+func (app *application) writeWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrWebhookNotFound) {
+		app.writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	app.writeRepositoryError(w, r, err)
+}
+
+// createWebhookHandler subscribes a URL to user events in the caller's
+// organization. The signing secret is only ever returned in this response.
+// POST /api/v1/webhooks
+//
+//	curl -X POST -H "Content-Type: application/json" \
+//	 -d '{"url": "https://example.com/hooks", "events": ["user.created"]}' \
+//	 http://localhost:8080/api/v1/webhooks
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permWebhooksManage)
+	if !ok {
+		return
+	}
+
+	var input webhookInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.writeBadRequest(w, r, err)
+		return
+	}
+
+	hook := Webhook{
+		ID:        app.ids.NewID(),
+		OrgID:     m.OrgID,
+		URL:       input.URL,
+		Events:    input.Events,
+		Secret:    input.Secret,
+		CreatedAt: time.Now(),
+	}
+	if hook.Secret == "" {
+		hook.Secret = newWebhookSecret()
+	}
+
+	created, err := app.webhooks.CreateWebhook(r.Context(), hook)
+	if err != nil {
+		app.writeWebhookError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusCreated, jsonResponse{
+		Status:  "success",
+		Message: "Webhook created successfully",
+		Data:    created,
+	})
+}
+
+// listWebhooksHandler lists the webhooks of the caller's organization.
+// GET /api/v1/webhooks
+// curl http://localhost:8080/api/v1/webhooks
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permWebhooksManage)
+	if !ok {
+		return
+	}
+
+	hooks, err := app.webhooks.ListWebhooks(r.Context(), m.OrgID)
+	if err != nil {
+		app.writeWebhookError(w, r, err)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status: "success",
+		Data:   hooks,
+	})
+}
+
+// getWebhookHandler retrieves a webhook.
+// GET /api/v1/webhooks/{id}
+// curl http://localhost:8080/api/v1/webhooks/{id}
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permWebhooksManage)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	hook, err := app.webhooks.GetWebhook(r.Context(), m.OrgID, id)
+	if err != nil {
+		app.writeWebhookError(w, r, err)
+		return
+	}
+	hook.Secret = ""
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status: "success",
+		Data:   hook,
+	})
+}
+
+// deleteWebhookHandler unsubscribes a webhook. Deliveries already queued for
+// it are still attempted.
+// DELETE /api/v1/webhooks/{id}
+// curl -X DELETE http://localhost:8080/api/v1/webhooks/{id}
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permWebhooksManage)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := app.webhooks.DeleteWebhook(r.Context(), m.OrgID, id); err != nil {
+		app.writeWebhookError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, jsonResponse{
+		Status:  "success",
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// listDeadLettersHandler lists the deliveries to a webhook that failed
+// permanently, newest first.
+// GET /api/v1/webhooks/{id}/dead-letters
+// curl http://localhost:8080/api/v1/webhooks/{id}/dead-letters
+func (app *application) listDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.authorize(w, r, permWebhooksManage)
+	if !ok {
+		return
+	}
+	id, ok := app.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := app.webhooks.GetWebhook(r.Context(), m.OrgID, id); err != nil {
+		app.writeWebhookError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, listResponse{
+		Status: "success",
+		Data:   app.dispatcher.DeadLetters(m.OrgID, id),
+	})
+}